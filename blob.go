@@ -0,0 +1,196 @@
+package gitconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxSymrefDepth limits depth of symbolic refs, the same as git
+const maxSymrefDepth = 5
+
+// refRevParseRules are rules to expand short name of a revision, the
+// same as git.
+var refRevParseRules = []string{
+	"%s",
+	"refs/%s",
+	"refs/tags/%s",
+	"refs/heads/%s",
+	"refs/remotes/%s",
+	"refs/remotes/%s/HEAD",
+}
+
+// hashSize returns size of object id in bytes, which is decided by
+// "extensions.objectFormat".
+func (v Repository) hashSize() int {
+	if strings.ToLower(v.gitConfig.Get("extensions.objectFormat")) == "sha256" {
+		return 32
+	}
+	return 20
+}
+
+// objectStore returns objectStore of the repository
+func (v Repository) objectStore() *objectStore {
+	return newObjectStore(v.fsys, v.gitCommonDir, v.hashSize())
+}
+
+// ReadBlob reads contents of blob from object database of the repository
+// without git. If the revision or the path does not exist, the error
+// satisfies os.IsNotExist. The blob is given as "<rev>:<path>" (e.g. "HEAD:.gitmodules"),
+// or object id of a blob. Revision can be a full object id or a ref,
+// followed by "~<n>" or "^<n>" to select an ancestor.
+func (v Repository) ReadBlob(rev string) ([]byte, error) {
+	store := v.objectStore()
+	defer store.close()
+
+	var (
+		oid  string
+		err  error
+		path string
+	)
+	name := rev
+	hasPath := false
+	if i := strings.Index(rev, ":"); i >= 0 {
+		name, path, hasPath = rev[:i], rev[i+1:], true
+	}
+	if name == "" {
+		return nil, fmt.Errorf("unable to resolve '%s': reading from index is not supported", rev)
+	}
+	if oid, err = v.resolveRevision(store, name); err != nil {
+		return nil, err
+	}
+
+	if hasPath {
+		for _, entry := range strings.Split(path, "/") {
+			if entry == "" {
+				continue
+			}
+			found := false
+			if oid, found, err = store.treeEntry(oid, entry); err != nil {
+				return nil, err
+			} else if !found {
+				return nil, &os.PathError{Op: "resolve", Path: rev, Err: os.ErrNotExist}
+			}
+		}
+	}
+
+	_, data, err := store.peel(oid, objBlob)
+	if err != nil {
+		return nil, fmt.Errorf("reference '%s' does not point to a blob: %s", rev, err)
+	}
+	return data, nil
+}
+
+// LoadBlob loads config from blob of the repository, the same as
+// "git config --blob". See ReadBlob for syntax of rev.
+func (v Repository) LoadBlob(rev string) (GitConfig, error) {
+	data, err := v.ReadBlob(rev)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := parse(v.fsys, data, Origin{Type: OriginBlob, Name: rev}, nil, 0, nil)
+	return cfg, err
+}
+
+// resolveRevision resolves revision which is an object id or a ref,
+// with optional suffixes "~<n>" and "^<n>".
+func (v Repository) resolveRevision(store *objectStore, rev string) (string, error) {
+	var (
+		oid string
+		err error
+	)
+
+	name, suffix := rev, ""
+	if pos := strings.IndexAny(rev, "~^"); pos >= 0 {
+		name, suffix = rev[:pos], rev[pos:]
+	}
+	if store.validObjectID(name) {
+		oid = strings.ToLower(name)
+	} else if oid, err = v.resolveRef(name); err != nil {
+		return "", err
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		i := 1
+		for i < len(suffix) && isdigit(suffix[i]) {
+			i++
+		}
+		n := 1
+		if i > 1 {
+			if n, err = strconv.Atoi(suffix[1:i]); err != nil {
+				return "", fmt.Errorf("unable to resolve revision '%s'", rev)
+			}
+		}
+		suffix = suffix[i:]
+		if op == '~' {
+			for ; n > 0; n-- {
+				if oid, err = store.parent(oid, 1); err != nil {
+					return "", fmt.Errorf("unable to resolve revision '%s': %s", rev, err)
+				}
+			}
+		} else if op == '^' {
+			if oid, err = store.parent(oid, n); err != nil {
+				return "", fmt.Errorf("unable to resolve revision '%s': %s", rev, err)
+			}
+		} else {
+			return "", fmt.Errorf("unable to resolve revision '%s'", rev)
+		}
+	}
+	return oid, nil
+}
+
+// resolveRef resolves short name of a ref using refRevParseRules
+func (v Repository) resolveRef(name string) (string, error) {
+	for _, rule := range refRevParseRules {
+		oid, err := v.readRef(fmt.Sprintf(rule, name), 0)
+		if err == nil {
+			return oid, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", &os.PathError{Op: "resolve", Path: name, Err: os.ErrNotExist}
+}
+
+// readRef reads ref from loose ref files (in gitdir and in commondir),
+// and from packed-refs. Symbolic refs are followed.
+func (v Repository) readRef(refname string, depth int) (string, error) {
+	if depth > maxSymrefDepth {
+		return "", fmt.Errorf("symbolic ref '%s' is too deep", refname)
+	}
+	dirs := []string{v.gitDir}
+	if v.gitCommonDir != v.gitDir {
+		dirs = append(dirs, v.gitCommonDir)
+	}
+	for _, dir := range dirs {
+		data, err := readFile(v.fsys, filepath.Join(dir, filepath.FromSlash(refname)))
+		if err != nil {
+			continue
+		}
+		content := strings.TrimSpace(string(data))
+		if strings.HasPrefix(content, "ref:") {
+			return v.readRef(strings.TrimSpace(strings.TrimPrefix(content, "ref:")), depth+1)
+		}
+		if isObjectID(content, v.hashSize()) {
+			return strings.ToLower(content), nil
+		}
+	}
+
+	data, err := readFile(v.fsys, filepath.Join(v.gitCommonDir, "packed-refs"))
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "" || line[0] == '#' || line[0] == '^' {
+				continue
+			}
+			fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+			if len(fields) == 2 && fields[1] == refname && isObjectID(fields[0], v.hashSize()) {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+	}
+	return "", &os.PathError{Op: "resolve", Path: refname, Err: os.ErrNotExist}
+}
@@ -0,0 +1,172 @@
+package gitconfig
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	testspace "github.com/Jiu2015/gotestspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBlob(t *testing.T) {
+	assert := assert.New(t)
+
+	ws, err := testspace.Create(
+		testspace.WithPathOption("testspace-*"),
+		testspace.WithShellOption(`
+			git init -q --initial-branch=main repo &&
+			cd repo &&
+			printf "[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/lib.git\n" >.gitmodules &&
+			mkdir conf &&
+			for i in $(seq 1 100); do
+				git config -f conf/team.config --add team.item "item $i" || return 1
+			done &&
+			git config -f conf/team.config team.name devops &&
+			git add . &&
+			test_tick &&
+			git commit -q -m initial &&
+			git tag -a -m v1 v1 &&
+			git config -f conf/team.config team.name ops &&
+			git commit -q -a -m second
+		`),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	defer ws.Cleanup()
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, stderr, err := ws.Execute(cancelCtx,
+		`git -C repo rev-parse v1:conf/team.config`)
+	assert.Nil(err, "stdout: %s\nstderr: %s", stdout, stderr)
+	blobID := strings.TrimSpace(stdout)
+
+	repo, err := FindRepository(ws.GetPath("repo"))
+	if !assert.Nil(err) {
+		return
+	}
+
+	check := func() {
+		cfg, err := repo.LoadBlob("HEAD:.gitmodules")
+		if assert.Nil(err) {
+			assert.Equal("https://example.com/lib.git", cfg.Get("submodule.lib.url"))
+			value, ok := cfg.GetWithOrigin("submodule.lib.path")
+			assert.True(ok)
+			assert.Equal(Origin{Type: OriginBlob, Name: "HEAD:.gitmodules", Line: 2}, value.Origin)
+		}
+
+		for _, tc := range []struct {
+			Rev  string
+			Name string
+		}{
+			{"HEAD:conf/team.config", "ops"},
+			{"main:conf/team.config", "ops"},
+			{"refs/heads/main:conf/team.config", "ops"},
+			{"v1:conf/team.config", "devops"},
+			{"HEAD~:conf/team.config", "devops"},
+			{"main^1:conf/team.config", "devops"},
+			{"v1^0:conf/team.config", "devops"},
+			{"refs/tags/v1:conf/team.config", "devops"},
+			{blobID, "devops"},
+		} {
+			cfg, err := repo.LoadBlob(tc.Rev)
+			if assert.Nil(err, tc.Rev) {
+				assert.Equal(tc.Name, cfg.Get("team.name"), tc.Rev)
+				assert.Equal(100, len(cfg.GetAll("team.item")), tc.Rev)
+			}
+		}
+
+		for _, rev := range []string{
+			"HEAD:missing",
+			"bad:conf/team.config",
+		} {
+			_, err = repo.LoadBlob(rev)
+			assert.True(os.IsNotExist(err), rev)
+		}
+
+		for _, rev := range []string{
+			"HEAD:missing",
+			"HEAD:conf",
+			":conf/team.config",
+			"bad:conf/team.config",
+			"HEAD~2:conf/team.config",
+			"HEAD^2:conf/team.config",
+		} {
+			_, err = repo.LoadBlob(rev)
+			assert.NotNil(err, rev)
+		}
+	}
+
+	// Loose objects and loose refs
+	check()
+
+	// Objects (and deltas) in packfile, and packed refs
+	stdout, stderr, err = ws.Execute(cancelCtx, `
+		cd repo &&
+		git repack -q -a -d -f --depth=50 --window=50 &&
+		git prune-packed &&
+		git pack-refs --all &&
+		test -z "$(find .git/objects -type f | grep -v pack | grep -v info)"
+	`)
+	assert.Nil(err, "stdout: %s\nstderr: %s", stdout, stderr)
+	check()
+}
+
+func TestApplyDelta(t *testing.T) {
+	assert := assert.New(t)
+
+	base := []byte("hello, world\n")
+	// source size 13, result size 17, copy 7 bytes from offset 0,
+	// insert "git\n", and copy 6 bytes from offset 7.
+	delta := []byte{13, 17, 0x90, 7, 4, 'g', 'i', 't', ' ', 0x91, 7, 6}
+	result, err := applyDelta(base, delta)
+	assert.Nil(err)
+	assert.Equal("hello, git world\n", string(result))
+
+	_, err = applyDelta(base[1:], delta)
+	assert.NotNil(err)
+	_, err = applyDelta(base, delta[:len(delta)-1])
+	assert.NotNil(err)
+
+	// Corrupt headers of delta, must fail without huge allocation
+	for _, delta := range [][]byte{
+		{0x8d},
+		{13, 0x80, 0x80},
+		{13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x3f, 0x90, 7},
+		{13, 0x80, 0x80, 0x80, 0x80, 0x10, 0x90, 7, 4, 'g', 'i', 't', ' '},
+		{13, 3, 0x90, 7},
+		{13, 3, 4, 'g', 'i', 't', ' '},
+		{13, 7, 0x91, 10, 7},
+	} {
+		_, err = applyDelta(base, delta)
+		assert.NotNil(err, "delta: %v", delta)
+	}
+}
+
+func TestInflate(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+
+	data, err := inflate(bytes.NewReader(buf.Bytes()), 5)
+	assert.Nil(err)
+	assert.Equal("hello", string(data))
+
+	// Size in header of object does not match
+	for _, size := range []uint64{0, 4, 6, 1 << 40, 1<<64 - 1} {
+		_, err = inflate(bytes.NewReader(buf.Bytes()), size)
+		assert.NotNil(err, "size: %d", size)
+	}
+
+	// Truncated data
+	_, err = inflate(bytes.NewReader(buf.Bytes()[:buf.Len()/2]), 5)
+	assert.NotNil(err)
+}
@@ -2,59 +2,331 @@ package gitconfig
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golang/groupcache/lru"
 )
 
-var cache *lru.Cache
+const (
+	// defaultCacheCapacity is default max number of entries in cache
+	defaultCacheCapacity = 128
+)
+
+var (
+	cache        *lru.Cache
+	cacheOptions = CacheOptions{Capacity: defaultCacheCapacity}
+	cacheStats   CacheStats
+	// cacheMutex protects cache, cacheOptions and cacheStats, lru.Cache
+	// is not safe for concurrent use
+	cacheMutex sync.Mutex
+)
+
+// CacheOptions holds options of cache for loaded config files
+type CacheOptions struct {
+	// Disabled disables cache, and config files are always loaded
+	Disabled bool
+	// Capacity is max number of entries in cache, 0 means the default
+	// (128), and a negative value means no limit
+	Capacity int
+	// MaxBytes is max total size of config files in cache, 0 means no
+	// limit
+	MaxBytes int64
+	// TTL is max age of entries in cache, 0 means no expiration
+	TTL time.Duration
+}
+
+// CacheStats holds statistics of cache
+type CacheStats struct {
+	// Entries is number of entries in cache
+	Entries int
+	// Bytes is total size of config files in cache
+	Bytes int64
+	// Hits is number of lookups which found up-to-date entries
+	Hits uint64
+	// Misses is number of lookups which found no usable entries,
+	// including stale and expired ones
+	Misses uint64
+	// Evictions is number of entries removed for capacity, size or TTL
+	Evictions uint64
+	// StaleReloads is number of entries removed for changed files,
+	// which will be loaded again
+	StaleReloads uint64
+}
+
+// cacheKey identifies entries in cache, config files of the same name
+// in different file systems are cached separately.
+type cacheKey struct {
+	fsys FS
+	name string
+}
+
+// comparableFS checks whether fsys can be used in cacheKey, config
+// loaded from file systems which are not comparable is not cached.
+func comparableFS(fsys FS) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return fsys == fsys
+}
 
 // cacheItem holds cache for git config.
 type cacheItem struct {
-	config   GitConfig
+	config  GitConfig
+	files   []fileStat
+	created time.Time
+}
+
+// size returns total size of files of the entry
+func (v *cacheItem) size() int64 {
+	var size int64
+
+	for _, file := range v.files {
+		size += file.size
+	}
+	return size
+}
+
+// fileStat records state of a file consulted while loading config,
+// including files which do not exist, such as a missing included file.
+type fileStat struct {
+	fsys     FS
 	filename string
+	exists   bool
 	time     time.Time
 	size     int64
+	inode    uint64
+}
+
+// newFileStat returns current state of file in fsys
+func newFileStat(fsys FS, filename string) fileStat {
+	stat := fileStat{fsys: fsys, filename: filename}
+	if fi, err := statFile(fsys, filename); err == nil {
+		stat.exists = true
+		stat.time = fi.ModTime()
+		stat.size = fi.Size()
+		stat.inode = fileInode(fi)
+	}
+	return stat
+}
+
+// uptodate checks whether the file is not changed, created or removed.
+// Inode is not checked if it is unknown (zero).
+func (v fileStat) uptodate() bool {
+	fi, err := statFile(v.fsys, v.filename)
+	if err != nil {
+		return !v.exists && os.IsNotExist(err)
+	}
+	if !v.exists || fi.ModTime() != v.time || fi.Size() != v.size {
+		return false
+	}
+	if v.inode != 0 && fileInode(fi) != v.inode {
+		return false
+	}
+	return true
+}
+
+// fileStats holds states of all files consulted while loading config,
+// nil fileStats does not record anything.
+type fileStats struct {
+	files []fileStat
+}
+
+// add records current state of file in fsys
+func (v *fileStats) add(fsys FS, filename string) {
+	if v == nil {
+		return
+	}
+	v.files = append(v.files, newFileStat(fsys, filename))
+}
+
+// merge records states of files which are already known
+func (v *fileStats) merge(files []fileStat) {
+	if v == nil {
+		return
+	}
+	v.files = append(v.files, files...)
 }
 
 func (v *cacheItem) uptodate() bool {
-	fi, err := os.Stat(v.filename)
-	if err == nil && fi.ModTime() == v.time && fi.Size() == v.size {
-		return true
+	for _, file := range v.files {
+		if !file.uptodate() {
+			return false
+		}
 	}
-	return false
+	return true
 }
 
-// CacheSet will set cache entry
+// CacheSet will set cache entry, a copy of cfg is saved in cache
 func CacheSet(key string, cfg GitConfig, size int64, modTime time.Time) {
-	if cache == nil {
+	fsys := GetFS()
+	cacheSet(fsys, key, cfg.Clone(), []fileStat{
+		{
+			fsys:     fsys,
+			filename: key,
+			exists:   true,
+			time:     modTime,
+			size:     size,
+		},
+	})
+}
+
+// cacheSet will set cache entry of name in fsys which is up-to-date
+// until any of the files is changed. cfg is saved without copy and
+// should not be modified any more.
+func cacheSet(fsys FS, name string, cfg GitConfig, files []fileStat) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if cache == nil || !comparableFS(fsys) {
 		return
 	}
-	cache.Add(key, &cacheItem{
-		config:   cfg,
-		filename: key,
-		time:     modTime,
-		size:     size,
-	})
+	key := cacheKey{fsys: fsys, name: name}
+	item := &cacheItem{
+		config:  cfg,
+		files:   files,
+		created: time.Now(),
+	}
+	if cacheOptions.MaxBytes > 0 && item.size() > cacheOptions.MaxBytes {
+		return
+	}
+	// Replacing an entry does not call OnEvicted of lru.Cache
+	if value, ok := cache.Get(key); ok {
+		cacheStats.Bytes -= value.(*cacheItem).size()
+	}
+	cache.Add(key, item)
+	cacheStats.Bytes += item.size()
+
+	capacity := cacheOptions.Capacity
+	if capacity == 0 {
+		capacity = defaultCacheCapacity
+	}
+	for cache.Len() > 0 &&
+		((capacity > 0 && cache.Len() > capacity) ||
+			(cacheOptions.MaxBytes > 0 && cacheStats.Bytes > cacheOptions.MaxBytes)) {
+		cache.RemoveOldest()
+		cacheStats.Evictions++
+	}
 }
 
-// CacheGet returns git config if config file is up-to-date
+// CacheGet returns a copy of git config if config file is up-to-date
 func CacheGet(key string) (GitConfig, bool) {
-	value, ok := cache.Get(key)
-	if !ok {
-		return nil, false
+	cfg, _, ok := cacheGet(GetFS(), key)
+	return cfg.Clone(), ok
+}
+
+// cacheGet returns git config of name in fsys in cache and states of
+// files it depends on. Both are shared by others without copy, and must
+// not be modified.
+func cacheGet(fsys FS, name string) (GitConfig, []fileStat, bool) {
+	cacheMutex.Lock()
+	if cache == nil || !comparableFS(fsys) {
+		cacheMutex.Unlock()
+		return nil, nil, false
 	}
-	item, ok := value.(*cacheItem)
+	key := cacheKey{fsys: fsys, name: name}
+	value, ok := cache.Get(key)
 	if !ok {
-		return nil, false
+		cacheStats.Misses++
+		cacheMutex.Unlock()
+		return nil, nil, false
 	}
-	if !item.uptodate() {
+	item := value.(*cacheItem)
+	if cacheOptions.TTL > 0 && time.Since(item.created) > cacheOptions.TTL {
 		cache.Remove(key)
-		return nil, false
+		cacheStats.Misses++
+		cacheStats.Evictions++
+		cacheMutex.Unlock()
+		return nil, nil, false
+	}
+	cacheMutex.Unlock()
+
+	// Check file without holding the lock
+	uptodate := item.uptodate()
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	if !uptodate {
+		cacheStats.Misses++
+		// Do not remove the entry if it was replaced by other goroutine
+		if value, ok := cache.Get(key); ok && value == item {
+			cache.Remove(key)
+			cacheStats.StaleReloads++
+		}
+		return nil, nil, false
+	}
+	cacheStats.Hits++
+	return item.config, item.files, true
+}
+
+// SetCacheOptions changes options of cache, and entries in cache are
+// dropped.
+func SetCacheOptions(options CacheOptions) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	cacheOptions = options
+	resetCache()
+}
+
+// GetCacheOptions returns options of cache
+func GetCacheOptions() CacheOptions {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	return cacheOptions
+}
+
+// GetCacheStats returns statistics of cache
+func GetCacheStats() CacheStats {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	stats := cacheStats
+	if cache != nil {
+		stats.Entries = cache.Len()
+	}
+	return stats
+}
+
+// ResetCacheStats resets counters of cache statistics
+func ResetCacheStats() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	cacheStats = CacheStats{Bytes: cacheStats.Bytes}
+}
+
+// CacheFlush drops all entries in cache
+func CacheFlush() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if cache != nil {
+		cache.Clear()
+	}
+}
+
+// resetCache creates empty cache using cacheOptions, should be called
+// with cacheMutex held.
+func resetCache() {
+	if cache != nil {
+		cache.Clear()
+	}
+	cacheStats.Bytes = 0
+	if cacheOptions.Disabled {
+		cache = nil
+		return
+	}
+	// Entries are evicted by cacheSet, for both capacity and size limits
+	cache = lru.New(0)
+	cache.OnEvicted = func(key lru.Key, value interface{}) {
+		cacheStats.Bytes -= value.(*cacheItem).size()
 	}
-	return item.config, true
 }
 
 func init() {
-	cache = lru.New(128)
+	resetCache()
 }
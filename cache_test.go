@@ -2,8 +2,13 @@ package gitconfig
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	testspace "github.com/Jiu2015/gotestspace"
 	"github.com/stretchr/testify/assert"
@@ -101,3 +106,273 @@ func TestCacheSetAgain(t *testing.T) {
 	assert.True(t, ok)
 	assert.NotNil(t, cfg)
 }
+
+func TestCacheConcurrentLoad(t *testing.T) {
+	var (
+		wg    sync.WaitGroup
+		files []string
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	// write config file atomically, so readers never see partial file
+	writeConfig := func(name string, value int) {
+		data := fmt.Sprintf("[include]\n\tpath = shared\n[test]\n\tvalue = %d\n", value)
+		tmpFile := fmt.Sprintf("%s.%d.tmp", name, value)
+		if err := ioutil.WriteFile(tmpFile, []byte(data), 0644); err != nil {
+			panic(err)
+		}
+		if err := os.Rename(tmpFile, name); err != nil {
+			panic(err)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpdir, "shared"), []byte("[test]\n\tshared = yes\n"), 0644)
+	assert.Nil(t, err)
+	for i := 0; i < 8; i++ {
+		name := filepath.Join(tmpdir, fmt.Sprintf("config-%d", i))
+		writeConfig(name, 0)
+		files = append(files, name)
+	}
+
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				name := files[(n+j)%len(files)]
+				if n%8 == 0 && j%10 == 0 {
+					writeConfig(name, n*1000+j)
+				}
+				cfg, err := LoadFile(name)
+				if assert.Nil(t, err) {
+					assert.Equal(t, "yes", cfg.Get("test.shared"))
+					assert.NotEqual(t, "", cfg.Get("test.value"))
+				}
+				if _, ok := CacheGet(name); ok {
+					CacheSet(name+".copy", cfg, 0, time.Time{})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCacheDefensiveCopy(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	cfgFile := filepath.Join(tmpdir, "config")
+	err = ioutil.WriteFile(cfgFile, []byte("[a]\n\tb = c\n"), 0644)
+	assert.Nil(err)
+
+	cfg, err := LoadFile(cfgFile)
+	assert.Nil(err)
+	cfg.Set("a.b", "changed")
+	cfg.Add("x.y", "z")
+	other := NewGitConfig()
+	other.Add("m.n", "o")
+	cfg.Merge(other, ScopeInclude)
+
+	cfg, err = LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("c", cfg.Get("a.b"))
+	assert.Equal([]string{"a.b"}, cfg.Keys())
+
+	cached, ok := CacheGet(cfgFile)
+	assert.True(ok)
+	cached.Set("a.b", "changed")
+	cached, ok = CacheGet(cfgFile)
+	assert.True(ok)
+	assert.Equal("c", cached.Get("a.b"))
+
+	clone := cached.Clone()
+	clone.Add("a.b", "d")
+	assert.Equal([]string{"c"}, cached.GetAll("a.b"))
+	assert.Equal([]string{"c", "d"}, clone.GetAll("a.b"))
+	assert.Nil(GitConfig(nil).Clone())
+}
+
+func TestCacheIncludedFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	cfgFile := filepath.Join(tmpdir, "config")
+	incFile := filepath.Join(tmpdir, "inc")
+	missingFile := filepath.Join(tmpdir, "missing")
+	err = ioutil.WriteFile(cfgFile, []byte("[include]\n\tpath = inc\n\tpath = missing\n"), 0644)
+	assert.Nil(err)
+	err = ioutil.WriteFile(incFile, []byte("[a]\n\tb = 1\n"), 0644)
+	assert.Nil(err)
+
+	cfg, err := LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("1", cfg.Get("a.b"))
+
+	// Included file is changed
+	err = ioutil.WriteFile(incFile, []byte("[a]\n\tb = 22\n"), 0644)
+	assert.Nil(err)
+	cfg, err = LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("22", cfg.Get("a.b"))
+
+	// Included file is replaced by another file with the same size and
+	// modification time
+	fi, err := os.Stat(incFile)
+	assert.Nil(err)
+	newFile := filepath.Join(tmpdir, "inc.new")
+	err = ioutil.WriteFile(newFile, []byte("[a]\n\tb = 33\n"), 0644)
+	assert.Nil(err)
+	assert.Nil(os.Chtimes(newFile, fi.ModTime(), fi.ModTime()))
+	assert.Nil(os.Rename(newFile, incFile))
+	cfg, err = LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("33", cfg.Get("a.b"))
+
+	// Missing included file appears
+	err = ioutil.WriteFile(missingFile, []byte("[a]\n\tb = 44\n"), 0644)
+	assert.Nil(err)
+	cfg, err = LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("44", cfg.Get("a.b"))
+
+	// Included file disappears
+	assert.Nil(os.Remove(missingFile))
+	cfg, err = LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal("33", cfg.Get("a.b"))
+}
+
+func TestCacheOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	defer SetCacheOptions(GetCacheOptions())
+	assert.Equal(CacheOptions{Capacity: 128}, GetCacheOptions())
+
+	files := []string{}
+	for i := 0; i < 3; i++ {
+		file := filepath.Join(tmpdir, fmt.Sprintf("config-%d", i))
+		// Each file has 11 bytes
+		err = ioutil.WriteFile(file, []byte(fmt.Sprintf("[a]\n\tb = %d\n", i)), 0644)
+		assert.Nil(err)
+		files = append(files, file)
+	}
+	load := func(file string) {
+		_, err := LoadFile(file)
+		assert.Nil(err)
+	}
+
+	// Capacity
+	SetCacheOptions(CacheOptions{Capacity: 2})
+	ResetCacheStats()
+	load(files[0])
+	load(files[0])
+	load(files[1])
+	load(files[2])
+	load(files[0])
+	assert.Equal(CacheStats{
+		Entries:   2,
+		Bytes:     22,
+		Hits:      1,
+		Misses:    4,
+		Evictions: 2,
+	}, GetCacheStats())
+
+	// Stale reloads
+	err = ioutil.WriteFile(files[0], []byte("[a]\n\tb = 10\n"), 0644)
+	assert.Nil(err)
+	load(files[0])
+	stats := GetCacheStats()
+	assert.Equal(uint64(1), stats.StaleReloads)
+	assert.Equal(int64(23), stats.Bytes)
+
+	// Flush
+	CacheFlush()
+	stats = GetCacheStats()
+	assert.Equal(0, stats.Entries)
+	assert.Equal(int64(0), stats.Bytes)
+
+	// Max bytes
+	SetCacheOptions(CacheOptions{MaxBytes: 20})
+	ResetCacheStats()
+	load(files[1])
+	load(files[2])
+	load(files[0])
+	assert.Equal(CacheStats{
+		Entries:   1,
+		Bytes:     12,
+		Misses:    3,
+		Evictions: 2,
+	}, GetCacheStats())
+
+	// TTL
+	SetCacheOptions(CacheOptions{TTL: 10 * time.Millisecond})
+	ResetCacheStats()
+	load(files[1])
+	load(files[1])
+	time.Sleep(20 * time.Millisecond)
+	load(files[1])
+	assert.Equal(CacheStats{
+		Entries:   1,
+		Bytes:     11,
+		Hits:      1,
+		Misses:    2,
+		Evictions: 1,
+	}, GetCacheStats())
+
+	// Default capacity is kept if only other options are set, and
+	// negative capacity means no limit
+	for i := len(files); i < defaultCacheCapacity+2; i++ {
+		file := filepath.Join(tmpdir, fmt.Sprintf("config-%d", i))
+		err = ioutil.WriteFile(file, []byte("[a]\n\tb = 1\n"), 0644)
+		assert.Nil(err)
+		files = append(files, file)
+	}
+	SetCacheOptions(CacheOptions{TTL: time.Minute, MaxBytes: 1 << 20})
+	for _, file := range files {
+		load(file)
+	}
+	assert.Equal(defaultCacheCapacity, GetCacheStats().Entries)
+	SetCacheOptions(CacheOptions{Capacity: -1})
+	for _, file := range files {
+		load(file)
+	}
+	assert.Equal(len(files), GetCacheStats().Entries)
+
+	// Disabled
+	SetCacheOptions(CacheOptions{Disabled: true})
+	ResetCacheStats()
+	load(files[1])
+	load(files[1])
+	_, ok := CacheGet(files[1])
+	assert.False(ok)
+	assert.Equal(CacheStats{}, GetCacheStats())
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/jiangxin/gitconfig"
 	flag "github.com/spf13/pflag"
@@ -12,15 +15,27 @@ var (
 	optGlobal         bool
 	optSystem         bool
 	optLocal          bool
+	optWorktree       bool
 	optFilename       string
+	optBlob           string
 	optInclude        bool
 	optActionGet      bool
 	optActionGetAll   bool
+	optActionURLMatch bool
 	optActionAdd      bool
 	optActionSet      bool
 	optActionUnset    bool
 	optActionUnsetAll bool
 	optActionList     bool
+	optShowOrigin     bool
+	optShowScope      bool
+	optType           string
+	optConfigParams   []string
+
+	// config file is discovered from current directory
+	optDiscover bool
+	// action will write config file
+	writeAction bool
 
 	configFile string
 	cfg        gitconfig.GitConfig
@@ -28,12 +43,15 @@ var (
 
 func checkOptions() error {
 	var (
-		scopes      = 0
-		actions     = 0
-		writeAction bool
-		err         error
+		scopes  = 0
+		actions = 0
+		err     error
 	)
 
+	// Bad GIT_CONFIG_NOSYSTEM is fatal, the same as git
+	if _, err = gitconfig.SystemConfigEnabledE(); err != nil {
+		return err
+	}
 	if optSystem {
 		configFile = gitconfig.SystemConfigFile()
 		scopes++
@@ -52,10 +70,20 @@ func checkOptions() error {
 		}
 		scopes++
 	}
+	if optWorktree {
+		configFile, err = gitconfig.FindWorktreeConfig("")
+		if err != nil {
+			return err
+		}
+		scopes++
+	}
 	if optFilename != "" {
 		configFile = optFilename
 		scopes++
 	}
+	if optBlob != "" {
+		scopes++
+	}
 	if scopes > 1 {
 		return fmt.Errorf("only one config file at a time")
 	}
@@ -66,6 +94,9 @@ func checkOptions() error {
 	if optActionGetAll {
 		actions++
 	}
+	if optActionURLMatch {
+		actions++
+	}
 	if optActionList {
 		actions++
 	}
@@ -94,6 +125,15 @@ func checkOptions() error {
 	if actions > 1 {
 		return fmt.Errorf("only one action at a time")
 	}
+	if _, err = formatType("", "0", false); err == errBadType {
+		return err
+	}
+	if optBlob != "" {
+		if writeAction {
+			return fmt.Errorf("writing config blobs is not supported")
+		}
+		return nil
+	}
 	if configFile == "" {
 		configFile, err = gitconfig.FindGitConfig("")
 		if err != nil {
@@ -102,21 +142,174 @@ func checkOptions() error {
 			}
 		}
 		optInclude = true
+		optDiscover = true
 	}
 
 	return nil
 }
 
+// formatValue shows value with scope and origin if necessary, the same
+// format as "git config --show-scope --show-origin".
+func formatValue(v gitconfig.ConfigValue, withKey bool) string {
+	line := v.Value
+	if withKey {
+		line = v.Key + "=" + v.Value
+		if v.NoValue {
+			line = v.Key
+		}
+	}
+	if optShowOrigin {
+		line = v.Origin.String() + "\t" + line
+	}
+	if optShowScope {
+		line = v.Scope + "\t" + line
+	}
+	return line
+}
+
+// errNotFound exits with status 1 without message, like git for missing
+// variables
+var errNotFound = fmt.Errorf("not found")
+
+var errBadType = fmt.Errorf("unrecognized --type argument, should be one of: bool, int, bool-or-int, bool-or-str, path, expiry-date, color")
+
+// formatType canonicalizes value according to "--type", the same as
+// "git config --type". Variable without value (noValue) is true.
+func formatType(key, value string, noValue bool) (string, error) {
+	switch optType {
+	case "":
+		return value, nil
+	case "bool":
+		if noValue {
+			return "true", nil
+		}
+		result, err := gitconfig.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("bad boolean config value '%s' for '%s'", value, key)
+		}
+		return strconv.FormatBool(result), nil
+	case "int":
+		result, err := gitconfig.ParseInt64(value)
+		if err != nil {
+			return "", numError(key, err)
+		}
+		return strconv.FormatInt(result, 10), nil
+	case "bool-or-int":
+		if noValue {
+			return "true", nil
+		}
+		result, isBool, err := gitconfig.ParseBoolOrInt(value)
+		if err != nil {
+			return "", numError(key, err)
+		}
+		if isBool {
+			return strconv.FormatBool(result != 0), nil
+		}
+		return strconv.Itoa(result), nil
+	case "bool-or-str":
+		if noValue {
+			return "true", nil
+		}
+		if result, err := gitconfig.ParseBool(value); err == nil {
+			return strconv.FormatBool(result), nil
+		}
+		return value, nil
+	case "path":
+		if noValue {
+			return "", fmt.Errorf("missing value for '%s'", key)
+		}
+		return gitconfig.ExpandPath(value)
+	case "expiry-date":
+		if noValue {
+			return "", fmt.Errorf("missing value for '%s'", key)
+		}
+		result, err := gitconfig.ParseExpiryDate(value)
+		if err != nil {
+			return "", fmt.Errorf("'%s' for '%s' is not a valid timestamp", value, key)
+		}
+		if result.Equal(gitconfig.ExpiryAll) {
+			return strconv.FormatUint(math.MaxUint64, 10), nil
+		}
+		return strconv.FormatInt(result.Unix(), 10), nil
+	case "color":
+		if noValue {
+			return "", fmt.Errorf("missing value for '%s'", key)
+		}
+		return gitconfig.ParseColor(value)
+	}
+	return "", errBadType
+}
+
+// normalizeType checks value to write according to "--type". Like git,
+// paths, expiry dates and colors are saved as is.
+func normalizeType(key, value string) (string, error) {
+	switch optType {
+	case "path", "expiry-date":
+		return value, nil
+	case "color":
+		if _, err := gitconfig.ParseColor(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	return formatType(key, value, false)
+}
+
+// numError adds key to error of parsing numbers
+func numError(key string, err error) error {
+	if e, ok := err.(*gitconfig.NumError); ok {
+		e.Key = key
+	}
+	return err
+}
+
 func runGet(args ...string) error {
+	var err error
 	for _, k := range args {
-		fmt.Println(cfg.Get(k))
+		v, ok := cfg.GetWithOrigin(k)
+		if !ok {
+			fmt.Println()
+			continue
+		}
+		if v.Value, err = formatType(v.Key, v.Value, v.NoValue); err != nil {
+			return err
+		}
+		fmt.Println(formatValue(v, false))
 	}
 	return nil
 }
 func runGetAll(args ...string) error {
+	var err error
 	for _, k := range args {
-		for _, v := range cfg.GetAll(k) {
-			fmt.Println(v)
+		for _, v := range cfg.GetAllWithOrigin(k) {
+			if v.Value, err = formatType(v.Key, v.Value, v.NoValue); err != nil {
+				return err
+			}
+			fmt.Println(formatValue(v, false))
+		}
+	}
+	return nil
+}
+
+func runGetURLMatch(args ...string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("wrong number of arguments, should be 2")
+	}
+	values, err := cfg.URLMatch(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return errNotFound
+	}
+	for _, v := range values {
+		if v.Value, err = formatType(v.Key, v.Value, v.NoValue); err != nil {
+			return err
+		}
+		if strings.Contains(args[0], ".") {
+			fmt.Println(v.Value)
+		} else {
+			fmt.Println(v.Key + " " + v.Value)
 		}
 	}
 	return nil
@@ -127,8 +320,8 @@ func runList(args ...string) error {
 		return fmt.Errorf("wrong number of arguments, should be 0")
 	}
 	for _, k := range cfg.Keys() {
-		for _, v := range cfg.GetAll(k) {
-			fmt.Printf("%s=%s\n", k, v)
+		for _, v := range cfg.GetAllWithOrigin(k) {
+			fmt.Println(formatValue(v, true))
 		}
 	}
 	return nil
@@ -138,7 +331,11 @@ func runAdd(args ...string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("wrong number of arguments, should be 2")
 	}
-	cfg.Add(args[0], args[1])
+	value, err := normalizeType(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	cfg.Add(args[0], value)
 	return cfg.Save(configFile)
 }
 
@@ -146,7 +343,11 @@ func runSet(args ...string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("wrong number of arguments, should be 2")
 	}
-	cfg.Set(args[0], args[1])
+	value, err := normalizeType(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	cfg.Set(args[0], value)
 	return cfg.Save(configFile)
 }
 
@@ -175,10 +376,39 @@ func main() {
 		os.Exit(1)
 	}
 
-	if optInclude {
+	for _, param := range optConfigParams {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 1 {
+			// "-c key" without value, which is true as boolean
+			err = gitconfig.AddConfigParameterNoValue(kv[0])
+		} else {
+			err = gitconfig.AddConfigParameter(kv[0], kv[1])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if optBlob != "" {
+		var repo *gitconfig.Repository
+
+		repo, err = gitconfig.FindRepository("")
+		if err == nil {
+			cfg, err = repo.LoadBlob(optBlob)
+		}
+	} else if optDiscover {
+		// Load repository config and per-worktree config of current
+		// directory, with default config.
+		cfg, err = gitconfig.LoadDirWithDefault("")
+	} else if optInclude {
 		cfg, err = gitconfig.LoadFileWithDefault(configFile)
 	} else {
 		cfg, err = gitconfig.LoadFile(configFile)
+		// config file will be created by write actions
+		if err == gitconfig.ErrNotExist && writeAction {
+			cfg, err = gitconfig.NewGitConfig(), nil
+		}
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
@@ -189,6 +419,8 @@ func main() {
 		err = runGet(flag.Args()...)
 	} else if optActionGetAll {
 		err = runGetAll(flag.Args()...)
+	} else if optActionURLMatch {
+		err = runGetURLMatch(flag.Args()...)
 	} else if optActionList {
 		err = runList(flag.Args()...)
 	} else if optActionAdd {
@@ -201,7 +433,9 @@ func main() {
 		err = runUnsetAll(flag.Args()...)
 	}
 
-	if err != nil {
+	if err == errNotFound {
+		os.Exit(1)
+	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		os.Exit(1)
 	}
@@ -212,14 +446,23 @@ func init() {
 	flag.BoolVar(&optGlobal, "global", false, "use global config file")
 	flag.BoolVar(&optSystem, "system", false, "use system config file")
 	flag.BoolVar(&optLocal, "local", false, "use local config file")
+	flag.BoolVar(&optWorktree, "worktree", false, "use per-worktree config file")
 	flag.BoolVar(&optInclude, "include", false, "respect include directives on lookup")
 	flag.StringVarP(&optFilename, "file", "f", "", "file to load")
+	flag.StringVar(&optBlob, "blob", "", "read config from given blob object: <rev>:<path> or blob-id")
+	flag.StringArrayVarP(&optConfigParams, "config", "c", nil, "pass a configuration parameter: name=value")
 	// action option
 	flag.BoolVar(&optActionGet, "get", false, "get value: name")
 	flag.BoolVar(&optActionGetAll, "get-all", false, "get value: name")
+	flag.BoolVar(&optActionURLMatch, "get-urlmatch", false, "get value specific for the URL: section[.var] URL")
 	flag.BoolVar(&optActionAdd, "add", false, "gdd a new variable: name value")
 	flag.BoolVar(&optActionUnset, "unset", false, "remove a variable")
 	flag.BoolVar(&optActionUnsetAll, "unset-all", false, "remove all matches")
 	flag.BoolVarP(&optActionList, "list", "l", false, "list all")
+	// type option
+	flag.StringVarP(&optType, "type", "t", "", "value is given this type: bool, int, bool-or-int, bool-or-str, path, expiry-date, color")
+	// display option
+	flag.BoolVar(&optShowOrigin, "show-origin", false, "show origin of config (file, command line, ...)")
+	flag.BoolVar(&optShowScope, "show-scope", false, "show scope of config (system, global, local, ...)")
 	flag.Parse()
 }
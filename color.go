@@ -0,0 +1,193 @@
+package gitconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// colorNames are names of ANSI colors, in the order of their codes
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// colorAttrs are names of attributes, their codes are index plus one,
+// and the negations are prefixed with "no" or "no-".
+var colorAttrs = []string{"bold", "dim", "italic", "ul", "blink", "", "reverse", "", "strike"}
+
+// colorAttrOff are codes to turn off attributes in colorAttrs
+var colorAttrOff = []int{22, 22, 23, 24, 25, 0, 27, 0, 29}
+
+// color is a parsed foreground or background color
+type color struct {
+	// kind is 0 for unspecified, 1 for normal, 2 for ANSI color
+	// (value is 0-7, or 9 for default), 3 for bright ANSI color, 4
+	// for 256 colors and 5 for RGB colors.
+	kind  int
+	value int
+	r     uint8
+	g     uint8
+	b     uint8
+}
+
+// parseColorWord parses a color name, number or "#rrggbb"
+func parseColorWord(word string) (color, bool) {
+	name := strings.ToLower(word)
+	if name == "normal" {
+		return color{kind: 1}, true
+	}
+	if name == "default" {
+		return color{kind: 2, value: 9}, true
+	}
+	if len(name) == 7 && name[0] == '#' {
+		rgb, err := strconv.ParseUint(name[1:], 16, 32)
+		if err != nil {
+			return color{}, false
+		}
+		return color{kind: 5, r: uint8(rgb >> 16), g: uint8(rgb >> 8), b: uint8(rgb)}, true
+	}
+	kind := 2
+	if strings.HasPrefix(name, "bright") {
+		kind = 3
+		name = name[len("bright"):]
+	}
+	for i, c := range colorNames {
+		if name == c {
+			return color{kind: kind, value: i}, true
+		}
+	}
+	if kind == 3 {
+		return color{}, false
+	}
+
+	n, err := strconv.Atoi(word)
+	if err != nil {
+		return color{}, false
+	}
+	switch {
+	case n == -1:
+		return color{kind: 1}, true
+	case n >= 0 && n < 8:
+		return color{kind: 2, value: n}, true
+	case n >= 8 && n < 16:
+		return color{kind: 3, value: n - 8}, true
+	case n >= 16 && n < 256:
+		return color{kind: 4, value: n}, true
+	}
+	return color{}, false
+}
+
+// parseColorAttr returns the code of attribute word, or -1
+func parseColorAttr(word string) int {
+	negate := false
+	if strings.HasPrefix(word, "no") {
+		negate = true
+		word = strings.TrimPrefix(word[2:], "-")
+	}
+	for i, attr := range colorAttrs {
+		if attr != "" && word == attr {
+			if negate {
+				return colorAttrOff[i]
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// code returns ANSI code of color, background adds 10 to the code
+func (c color) code(background bool) string {
+	offset := 0
+	if background {
+		offset = 10
+	}
+	switch c.kind {
+	case 2:
+		return strconv.Itoa(30 + offset + c.value)
+	case 3:
+		return strconv.Itoa(90 + offset + c.value)
+	case 4:
+		return fmt.Sprintf("%d;5;%d", 38+offset, c.value)
+	case 5:
+		return fmt.Sprintf("%d;2;%d;%d;%d", 38+offset, c.r, c.g, c.b)
+	}
+	return ""
+}
+
+// ParseColor parses value of a color config variable, such as "bold red
+// blue", and returns ANSI escape sequence of it, the same as git.
+func ParseColor(value string) (string, error) {
+	var (
+		fg, bg color
+		attrs  = make(map[int]bool)
+		reset  = false
+	)
+
+	for _, word := range strings.Fields(value) {
+		if strings.EqualFold(word, "reset") {
+			reset = true
+			continue
+		}
+		if c, ok := parseColorWord(word); ok {
+			if fg.kind == 0 {
+				fg = c
+			} else if bg.kind == 0 {
+				bg = c
+			} else {
+				return "", fmt.Errorf("invalid color value: %s", value)
+			}
+			continue
+		}
+		if code := parseColorAttr(word); code >= 0 {
+			attrs[code] = true
+			continue
+		}
+		return "", fmt.Errorf("invalid color value: %s", value)
+	}
+
+	codes := []string{}
+	if reset {
+		codes = append(codes, "")
+	}
+	for code := 1; code < 30; code++ {
+		if attrs[code] {
+			codes = append(codes, strconv.Itoa(code))
+		}
+	}
+	if code := fg.code(false); code != "" {
+		codes = append(codes, code)
+	}
+	if code := bg.code(true); code != "" {
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return "", nil
+	}
+	if reset && len(codes) == 1 {
+		return "\033[m", nil
+	}
+	return "\033[" + strings.Join(codes, ";") + "m", nil
+}
+
+// GetColor gets ANSI escape sequence of a color config variable with
+// default, such as "color.diff.meta", see ParseColor. The default is
+// used if the value is not a valid color.
+func (v GitConfig) GetColor(key string, defaultValue string) string {
+	result, err := v.GetColorE(key, defaultValue)
+	if err != nil {
+		result, _ = ParseColor(defaultValue)
+	}
+	return result
+}
+
+// GetColorE gets ANSI escape sequence of a color config variable with
+// default with error
+func (v GitConfig) GetColorE(key string, defaultValue string) (string, error) {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return ParseColor(defaultValue)
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return "", ErrMissingValue
+	}
+	return ParseColor(value.value)
+}
@@ -0,0 +1,62 @@
+package gitconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		Value  string
+		Expect string
+	}{
+		{"", ""},
+		{"normal", ""},
+		{"reset", "\033[m"},
+		{"reset bold", "\033[;1m"},
+		{"normal red", "\033[41m"},
+		{"default Yellow", "\033[39;43m"},
+		{"bold red ul #ff0000", "\033[1;4;31;48;2;255;0;0m"},
+		{"brightblue nobold no-ul", "\033[22;24;94m"},
+		{"196 17", "\033[38;5;196;48;5;17m"},
+		{"-1 0", "\033[40m"},
+		{"8 15", "\033[90;107m"},
+		{"italic strike dim blink reverse", "\033[2;3;5;7;9m"},
+	} {
+		result, err := ParseColor(tc.Value)
+		assert.Nil(err, tc.Value)
+		assert.Equal(tc.Expect, result, tc.Value)
+	}
+
+	for _, value := range []string{"red green blue", "underline", "256", "#ff00", "brightdefault", "Bold"} {
+		_, err := ParseColor(value)
+		assert.Equal("invalid color value: "+value, err.Error())
+	}
+}
+
+func TestGetColor(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cfg    = NewGitConfig()
+	)
+
+	cfg.Set("color.diff.meta", "yellow bold")
+	cfg.addNoValue("color.diff", "old", Origin{})
+
+	result, err := cfg.GetColorE("color.diff.meta", "")
+	assert.Nil(err)
+	assert.Equal("\033[1;33m", result)
+
+	result, err = cfg.GetColorE("color.diff.new", "green")
+	assert.Nil(err)
+	assert.Equal("\033[32m", result)
+
+	_, err = cfg.GetColorE("color.diff.old", "red")
+	assert.Equal(ErrMissingValue, err)
+
+	assert.Equal("\033[1;33m", cfg.GetColor("color.diff.meta", "red"))
+	assert.Equal("\033[31m", cfg.GetColor("color.diff.old", "red"))
+}
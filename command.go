@@ -0,0 +1,199 @@
+package gitconfig
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environments to pass config from command line, such as "git -c"
+const (
+	gitConfigParametersEnv = "GIT_CONFIG_PARAMETERS"
+	gitConfigCountEnv      = "GIT_CONFIG_COUNT"
+	gitConfigKeyEnv        = "GIT_CONFIG_KEY_"
+	gitConfigValueEnv      = "GIT_CONFIG_VALUE_"
+)
+
+// CommandConfig returns config from command line, which is passed by
+// environments GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n>, GIT_CONFIG_VALUE_<n>
+// and GIT_CONFIG_PARAMETERS (used by "git -c key=value").
+func CommandConfig() (GitConfig, error) {
+	cfg := NewGitConfig()
+	origin := Origin{Type: OriginCommandLine}
+
+	if env := os.Getenv(gitConfigCountEnv); env != "" {
+		count, err := strconv.Atoi(env)
+		if err != nil || count < 0 {
+			return cfg, fmt.Errorf("bogus count in %s", gitConfigCountEnv)
+		}
+		for i := 0; i < count; i++ {
+			key := os.Getenv(gitConfigKeyEnv + strconv.Itoa(i))
+			if key == "" {
+				return cfg, fmt.Errorf("missing config key %s%d", gitConfigKeyEnv, i)
+			}
+			value, ok := os.LookupEnv(gitConfigValueEnv + strconv.Itoa(i))
+			if !ok {
+				return cfg, fmt.Errorf("missing config value %s%d", gitConfigValueEnv, i)
+			}
+			if err := cfg.addCommandValue(key, value, false, origin); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	if env := os.Getenv(gitConfigParametersEnv); env != "" {
+		if err := cfg.parseConfigParameters(env, origin); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// addCommandValue adds key and value from command line, noValue is true
+// for "git -c key" without "=".
+func (v GitConfig) addCommandValue(key, value string, noValue bool, origin Origin) error {
+	section, k := toSectionKey(key)
+	if section == "" || k == "" {
+		return fmt.Errorf("invalid config key: %s", key)
+	}
+	if noValue {
+		v.addNoValue(section, k, origin)
+	} else {
+		v.addWithOrigin(section, k, origin, value)
+	}
+	return nil
+}
+
+// parseConfigParameters parses GIT_CONFIG_PARAMETERS, which has entries
+// in old style "'key=value'", or in new style "'key'='value'". Entries
+// without value are "'key'" in old style, or "'key'=" in new style.
+func (v GitConfig) parseConfigParameters(env string, origin Origin) error {
+	var (
+		cur  = env
+		key  string
+		more bool
+		ok   bool
+	)
+
+	for {
+		cur = strings.TrimLeft(cur, " \t\n\v\f\r")
+		if cur == "" {
+			return nil
+		}
+		key, cur, more, ok = sqDequoteStep(cur)
+		if !ok {
+			return fmt.Errorf("bogus format in %s", gitConfigParametersEnv)
+		}
+		if !more || isspace(cur[0]) {
+			// old style: 'key=value'
+			value := ""
+			noValue := true
+			if pos := strings.IndexByte(key, '='); pos >= 0 {
+				key, value, noValue = key[:pos], key[pos+1:], false
+			}
+			if err := v.addCommandValue(key, value, noValue, origin); err != nil {
+				return err
+			}
+		} else if cur[0] == '=' {
+			// new style: 'key'='value'
+			value := ""
+			noValue := true
+			cur = cur[1:]
+			if len(cur) > 0 && cur[0] == '\'' {
+				value, cur, more, ok = sqDequoteStep(cur)
+				if !ok || (more && !isspace(cur[0])) {
+					return fmt.Errorf("bogus format in %s", gitConfigParametersEnv)
+				}
+				noValue = false
+			} else if len(cur) > 0 && !isspace(cur[0]) {
+				return fmt.Errorf("bogus format in %s", gitConfigParametersEnv)
+			}
+			if err := v.addCommandValue(key, value, noValue, origin); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("bogus format in %s", gitConfigParametersEnv)
+		}
+	}
+}
+
+// sqDequoteStep dequotes one single-quoted word at the beginning of arg,
+// returns dequoted word, remaining string, whether there is remaining
+// string, and whether the format is OK. It is a port of git's
+// sq_dequote_step().
+func sqDequoteStep(arg string) (string, string, bool, bool) {
+	var dst []byte
+
+	if len(arg) == 0 || arg[0] != '\'' {
+		return "", "", false, false
+	}
+	src := 1
+	for {
+		if src >= len(arg) {
+			return "", "", false, false
+		}
+		c := arg[src]
+		if c != '\'' {
+			dst = append(dst, c)
+			src++
+			continue
+		}
+		// We stepped out of sq
+		src++
+		if src >= len(arg) {
+			return string(dst), "", false, true
+		}
+		if arg[src] == '\\' && src+2 < len(arg) &&
+			(arg[src+1] == '\'' || arg[src+1] == '!') && arg[src+2] == '\'' {
+			dst = append(dst, arg[src+1])
+			src += 3
+			continue
+		}
+		return string(dst), arg[src:], true, true
+	}
+}
+
+// sqQuote quotes string in single quotes, the same as git's sq_quote_buf()
+func sqQuote(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' || s[i] == '!' {
+			b.WriteString("'\\")
+			b.WriteByte(s[i])
+			b.WriteByte('\'')
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// AddConfigParameter adds key and value to GIT_CONFIG_PARAMETERS of
+// current process, like "git -c key=value". These config will be
+// loaded by CommandConfig, and passed to git commands started.
+func AddConfigParameter(key, value string) error {
+	return addConfigParameter(key, sqQuote(key)+"="+sqQuote(value))
+}
+
+// AddConfigParameterNoValue adds key without value to
+// GIT_CONFIG_PARAMETERS of current process, like "git -c key", which is
+// true as boolean.
+func AddConfigParameterNoValue(key string) error {
+	return addConfigParameter(key, sqQuote(key)+"=")
+}
+
+// addConfigParameter appends quoted entry of key to GIT_CONFIG_PARAMETERS
+func addConfigParameter(key, entry string) error {
+	if section, k := toSectionKey(key); section == "" || k == "" {
+		return fmt.Errorf("invalid config key: %s", key)
+	}
+	env := os.Getenv(gitConfigParametersEnv)
+	if env != "" {
+		env += " "
+	}
+	return os.Setenv(gitConfigParametersEnv, env+entry)
+}
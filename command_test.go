@@ -0,0 +1,107 @@
+package gitconfig
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfigParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		Env    string
+		Keys   []string
+		Values []string
+		Err    bool
+	}{
+		{`'a.b=c'`, []string{"a.b"}, []string{"c"}, false},
+		{` 'a.b=c'  'A.x.Y=d e' `, []string{"a.b", "a.x.y"}, []string{"c", "d e"}, false},
+		{`'a.b'='c' 'a.d'= 'a.e'`, []string{"a.b", "a.d", "a.e"}, []string{"c", "", ""}, false},
+		{`'Foo.Bar'='it'\''s '\!'x'`, []string{"foo.bar"}, []string{"it's !x"}, false},
+		{`'a.b'='c`, nil, nil, true},
+		{`a.b=c`, nil, nil, true},
+		{`'a.b'x`, nil, nil, true},
+		{`'a.b'='c'd`, nil, nil, true},
+		{`'novalue'='c'`, nil, nil, true},
+	} {
+		cfg := NewGitConfig()
+		err := cfg.parseConfigParameters(tc.Env, Origin{Type: OriginCommandLine})
+		if tc.Err {
+			assert.NotNil(err, "env: %s", tc.Env)
+			continue
+		}
+		assert.Nil(err, "env: %s", tc.Env)
+		for i, key := range tc.Keys {
+			assert.Equal(tc.Values[i], cfg.Get(key), "env: %s, key: %s", tc.Env, key)
+		}
+	}
+
+	// Values are missing for "'key'" and "'key'=", but not for "'key'=''"
+	cfg := NewGitConfig()
+	assert.Nil(cfg.parseConfigParameters(`'a.b' 'a.c'= 'a.d'='' 'a.e='`, Origin{Type: OriginCommandLine}))
+	for key, noValue := range map[string]bool{"a.b": true, "a.c": true, "a.d": false, "a.e": false} {
+		value, ok := cfg.GetWithOrigin(key)
+		assert.True(ok, key)
+		assert.Equal(noValue, value.NoValue, key)
+		assert.Equal(noValue, cfg.GetBool(key, false), key)
+	}
+}
+
+func TestCommandConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{
+		gitConfigParametersEnv,
+		gitConfigCountEnv,
+		gitConfigKeyEnv + "0",
+		gitConfigValueEnv + "0",
+		gitConfigKeyEnv + "1",
+		gitConfigValueEnv + "1",
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+		} else {
+			defer os.Unsetenv(name)
+		}
+		os.Unsetenv(name)
+	}
+
+	os.Setenv(gitConfigCountEnv, "2")
+	os.Setenv(gitConfigKeyEnv+"0", "test.key")
+	os.Setenv(gitConfigValueEnv+"0", "count 0")
+	os.Setenv(gitConfigKeyEnv+"1", "test.key")
+	os.Setenv(gitConfigValueEnv+"1", "count 1")
+	assert.Nil(AddConfigParameter("test.Key", "it's !param"))
+	assert.Nil(AddConfigParameterNoValue("test.flag"))
+
+	// Check format of GIT_CONFIG_PARAMETERS with git
+	output, err := exec.Command("git", "config", "--get-all", "test.key").Output()
+	assert.Nil(err)
+	assert.Equal("count 0\ncount 1\nit's !param\n", string(output))
+	output, err = exec.Command("git", "config", "--list").Output()
+	assert.Nil(err)
+	assert.Contains(string(output), "\ntest.flag\n")
+
+	cfg, err := CommandConfig()
+	assert.Nil(err)
+	assert.Equal([]string{"count 0", "count 1", "it's !param"}, cfg.GetAll("test.key"))
+	assert.True(cfg.GetBool("test.flag", false))
+
+	cfg = DefaultConfig()
+	value, ok := cfg.GetWithOrigin("test.key")
+	assert.True(ok)
+	assert.Equal("command\tcommand line:\ttest.key=it's !param", value.String())
+
+	os.Unsetenv(gitConfigValueEnv + "1")
+	_, err = CommandConfig()
+	assert.NotNil(err)
+	os.Setenv(gitConfigCountEnv, "bad")
+	_, err = CommandConfig()
+	assert.NotNil(err)
+
+	assert.NotNil(AddConfigParameter("novalue", "x"))
+	assert.NotNil(AddConfigParameterNoValue("novalue"))
+}
@@ -0,0 +1,204 @@
+package gitconfig
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Special expiry dates returned by ParseExpiryDate
+var (
+	// ExpiryNever is the expiry date of "never" or "false", which
+	// expires nothing.
+	ExpiryNever = time.Unix(0, 0)
+	// ExpiryAll is the expiry date of "now" or "all", which is later
+	// than any other time and expires everything.
+	ExpiryAll = time.Unix(math.MaxInt64-62135596800, 999999999)
+)
+
+// absoluteDateLayouts are layouts of absolute dates with time
+var absoluteDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon Jan 2 15:04:05 2006 -0700",
+	time.ANSIC,
+	time.UnixDate,
+}
+
+// dateOnlyLayouts are layouts of absolute dates without time, time of
+// now is used, the same as git.
+var dateOnlyLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006.01.02",
+	"Jan 2 2006",
+	"2 Jan 2006",
+}
+
+// dateUnits are units of relative dates, except month and year
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// weekdays are names of weekdays, git matches at least 3 letters
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// matchWeekday returns weekday which name starts with token
+func matchWeekday(token string) (time.Weekday, bool) {
+	if len(token) >= 3 {
+		for i, name := range weekdays {
+			if strings.HasPrefix(name, token) {
+				return time.Weekday(i), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// approxidate parses absolute dates and relative dates (e.g.
+// "2.weeks.ago", "yesterday", "last friday"), like approxidate of git.
+func approxidate(date string, now time.Time) (time.Time, error) {
+	s := strings.TrimSpace(date)
+	// Like git, large numbers are seconds since epoch
+	if n, err := strconv.ParseInt(strings.TrimPrefix(s, "@"), 10, 64); err == nil && n >= 100000000 {
+		return time.Unix(n, 0), nil
+	}
+	for _, layout := range absoluteDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range dateOnlyLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(),
+				now.Hour(), now.Minute(), now.Second(), 0, now.Location()), nil
+		}
+	}
+
+	result := now
+	touched := false
+	number := -1
+	for _, token := range strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return c > 127 || !isalpha(byte(c)) && !isdigit(byte(c))
+	}) {
+		if n, err := strconv.Atoi(token); err == nil {
+			number = n
+			continue
+		}
+		switch token {
+		case "ago":
+			continue
+		case "now", "today":
+			touched = true
+			continue
+		case "last":
+			number = 1
+			continue
+		case "yesterday":
+			result = result.AddDate(0, 0, -1)
+			touched = true
+			continue
+		case "noon", "midnight", "tea":
+			// Like date_time() of git, go back one day if the hour
+			// of the date so far has not come yet
+			hour := map[string]int{"noon": 12, "midnight": 0, "tea": 17}[token]
+			if result.Hour() < hour {
+				result = result.AddDate(0, 0, -1)
+			}
+			result = time.Date(result.Year(), result.Month(), result.Day(),
+				hour, 0, 0, 0, result.Location())
+			touched = true
+			continue
+		case "am", "pm":
+			if number < 1 || number > 12 {
+				return time.Time{}, fmt.Errorf("bad date '%s'", date)
+			}
+			hour := number % 12
+			if token == "pm" {
+				hour += 12
+			}
+			result = time.Date(result.Year(), result.Month(), result.Day(),
+				hour, 0, 0, 0, result.Location())
+			number = -1
+			touched = true
+			continue
+		}
+
+		n := number
+		if n < 0 {
+			n = 1
+		}
+		unit := strings.TrimSuffix(token, "s")
+		if duration, ok := dateUnits[unit]; ok {
+			result = result.Add(-time.Duration(n) * duration)
+		} else if unit == "month" {
+			result = result.AddDate(0, -n, 0)
+		} else if unit == "year" {
+			result = result.AddDate(-n, 0, 0)
+		} else if weekday, ok := matchWeekday(token); ok && number > 0 {
+			diff := int(result.Weekday()) - int(weekday)
+			if diff <= 0 {
+				diff += 7
+			}
+			result = result.AddDate(0, 0, -diff-7*(number-1))
+		} else {
+			return time.Time{}, fmt.Errorf("bad date '%s'", date)
+		}
+		number = -1
+		touched = true
+	}
+	if !touched || number >= 0 {
+		return time.Time{}, fmt.Errorf("bad date '%s'", date)
+	}
+	return result, nil
+}
+
+// ParseExpiryDate parses value as an expiry date the same as git, such
+// as "2.weeks.ago" and "2006-01-02". It returns ExpiryNever for "never"
+// and "false", and ExpiryAll for "now" and "all".
+func ParseExpiryDate(value string) (time.Time, error) {
+	switch value {
+	case "never", "false":
+		return ExpiryNever, nil
+	case "now", "all":
+		return ExpiryAll, nil
+	}
+	return approxidate(value, time.Now())
+}
+
+// GetExpiryDate gets expiry date from key with default, such as
+// "gc.reflogExpire". See ParseExpiryDate for special values.
+func (v GitConfig) GetExpiryDate(key string, defaultValue time.Time) time.Time {
+	result, err := v.GetExpiryDateE(key, defaultValue)
+	if err != nil {
+		result = defaultValue
+	}
+	return result
+}
+
+// GetExpiryDateE gets expiry date from key with default with error
+func (v GitConfig) GetExpiryDateE(key string, defaultValue time.Time) (time.Time, error) {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return time.Time{}, ErrMissingValue
+	}
+	result, err := ParseExpiryDate(value.value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' for '%s' is not a valid timestamp", value.value, key)
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package gitconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApproxidate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		// Saturday, 2026-10-17 00:53:09 UTC
+		now = time.Unix(1792198389, 0).UTC()
+	)
+
+	for _, tc := range []struct {
+		Date   string
+		Expect int64
+	}{
+		{"2.weeks.ago", 1792198389 - 14*86400},
+		{"1.year", 1792198389 - 365*86400},
+		{"3.days", 1792198389 - 3*86400},
+		{"10 minutes ago", 1792198389 - 600},
+		{"yesterday", 1792198389 - 86400},
+		{"1 week 2 days ago", 1792198389 - 9*86400},
+		{"last week", 1792198389 - 7*86400},
+		{"last friday", 1792198389 - 86400},
+		{"noon", 1792152000},
+		{"midnight", 1792195200},
+		{"yesterday noon", 1792065600},
+		{"yesterday midnight", 1792108800},
+		{"tea yesterday", 1792083600},
+		{"5.pm noon", 1792238400},
+		{"5.pm", 1792256400},
+		{"2023-01-02", 1672620789},
+		{"2023-01-02 10:00:00 +0800", 1672624800},
+		{"Mon, 6 Jan 2020 10:00:00 +0100", 1578301200},
+		{"@1700000000", 1700000000},
+		{"1700000000", 1700000000},
+	} {
+		result, err := approxidate(tc.Date, now)
+		assert.Nil(err, tc.Date)
+		assert.Equal(tc.Expect, result.Unix(), tc.Date)
+	}
+
+	for _, date := range []string{"bogus", "friday", "5", ""} {
+		_, err := approxidate(date, now)
+		assert.NotNil(err, date)
+	}
+}
+
+func TestGetExpiryDate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cfg    = NewGitConfig()
+	)
+
+	cfg.Set("gc.reflogExpire", "never")
+	cfg.Set("gc.reflogExpireUnreachable", "now")
+	cfg.Set("gc.pruneExpire", "2023-01-02 10:00:00 +0800")
+	cfg.Set("gc.worktreePruneExpire", "bogus")
+	cfg.addNoValue("gc", "rerereresolved", Origin{})
+
+	result, err := cfg.GetExpiryDateE("gc.reflogExpire", time.Time{})
+	assert.Nil(err)
+	assert.Equal(ExpiryNever, result)
+
+	result, err = cfg.GetExpiryDateE("gc.reflogExpireUnreachable", time.Time{})
+	assert.Nil(err)
+	assert.Equal(ExpiryAll, result)
+	assert.True(result.After(time.Now().AddDate(1000, 0, 0)))
+
+	result, err = cfg.GetExpiryDateE("gc.pruneExpire", time.Time{})
+	assert.Nil(err)
+	assert.Equal(int64(1672624800), result.Unix())
+
+	_, err = cfg.GetExpiryDateE("gc.worktreePruneExpire", time.Time{})
+	assert.Equal("'bogus' for 'gc.worktreePruneExpire' is not a valid timestamp", err.Error())
+
+	_, err = cfg.GetExpiryDateE("gc.rerereResolved", time.Time{})
+	assert.Equal(ErrMissingValue, err)
+
+	result, err = cfg.GetExpiryDateE("gc.missing", ExpiryNever)
+	assert.Nil(err)
+	assert.Equal(ExpiryNever, result)
+
+	assert.Equal(ExpiryAll, cfg.GetExpiryDate("gc.reflogExpireUnreachable", ExpiryNever))
+	assert.Equal(ExpiryNever, cfg.GetExpiryDate("gc.worktreePruneExpire", ExpiryNever))
+}
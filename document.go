@@ -0,0 +1,305 @@
+package gitconfig
+
+import (
+	"strings"
+
+	"github.com/jiangxin/gitconfig/goconfig"
+)
+
+// Document holds contents of a config file together with positions of
+// sections and variables in it, so that the file can be edited in place
+// without losing comments, blank lines, order and spelling of keys.
+type Document struct {
+	data    []byte
+	entries []goconfig.Entry
+}
+
+// ParseDocument parses contents of a config file into Document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{data: data}
+	if err := doc.reload(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// reload parses data again to refresh positions of entries
+func (v *Document) reload() error {
+	entries, _, err := goconfig.ParseEntries(v.data)
+	if err != nil {
+		return err
+	}
+	v.entries = entries
+	return nil
+}
+
+// Bytes returns contents of the document
+func (v *Document) Bytes() []byte {
+	return v.data
+}
+
+// String returns contents of the document
+func (v *Document) String() string {
+	return string(v.data)
+}
+
+// entryKey returns section and key (in lower case) of a variable entry
+func entryKey(e goconfig.Entry) (string, string) {
+	if e.IsSection() || e.Section == "" {
+		return "", ""
+	}
+	return e.Section, strings.ToLower(e.Key[len(e.Section)+1:])
+}
+
+// find returns indexes of entries for the given variable
+func (v *Document) find(section, key string) []int {
+	result := []int{}
+	for i, e := range v.entries {
+		s, k := entryKey(e)
+		if s == section && k == key && k != "" {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// GetAll gets all values of a key from the document
+func (v *Document) GetAll(key string) []string {
+	section, key := toSectionKey(key)
+	values := []string{}
+	for _, i := range v.find(section, key) {
+		values = append(values, v.entries[i].Value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// Set replaces the last value of key in place, or adds a new one
+func (v *Document) Set(key string, value interface{}) error {
+	section, key := toSectionKey(key)
+	if found := v.find(section, key); len(found) > 0 {
+		return v.setValue(found[len(found)-1], toString(value), false)
+	}
+	return v.add(section, key, toString(value), false)
+}
+
+// Add adds new value of key after the last setting of the same key
+func (v *Document) Add(key string, value ...interface{}) error {
+	section, key := toSectionKey(key)
+	for _, val := range value {
+		if err := v.add(section, key, toString(val), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unset removes the last setting of key
+func (v *Document) Unset(key string) error {
+	section, key := toSectionKey(key)
+	if found := v.find(section, key); len(found) > 0 {
+		return v.remove(found[len(found)-1])
+	}
+	return nil
+}
+
+// UnsetAll removes all settings of key
+func (v *Document) UnsetAll(key string) error {
+	section, key := toSectionKey(key)
+	for {
+		found := v.find(section, key)
+		if len(found) == 0 {
+			return nil
+		}
+		if err := v.remove(found[len(found)-1]); err != nil {
+			return err
+		}
+	}
+}
+
+// update edits the document to hold the same variables as GitConfig
+// of ScopeSelf, only lines of changed variables are rewritten.
+func (v *Document) update(cfg GitConfig) error {
+	type sectionKey struct {
+		section string
+		key     string
+	}
+
+	names := []sectionKey{}
+	seen := make(map[sectionKey]bool)
+	for _, e := range v.entries {
+		s, k := entryKey(e)
+		name := sectionKey{s, k}
+		if k != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, s := range cfg.Sections() {
+		for _, k := range cfg[s].Keys() {
+			name := sectionKey{s, k}
+			if s != "" && k != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		want := []gitConfigValue{}
+		if cfg[name.section] != nil {
+			for _, value := range cfg[name.section][name.key] {
+				if value.isSelf() {
+					want = append(want, value)
+				}
+			}
+		}
+
+		found := v.find(name.section, name.key)
+		for i := 0; i < len(found) && i < len(want); i++ {
+			e := v.entries[found[i]]
+			if e.Value == want[i].value && e.NoValue == want[i].noValue {
+				continue
+			}
+			if err := v.setValue(found[i], want[i].value, want[i].noValue); err != nil {
+				return err
+			}
+		}
+		for i := len(found) - 1; i >= len(want); i-- {
+			if err := v.remove(found[i]); err != nil {
+				return err
+			}
+		}
+		for i := len(found); i < len(want); i++ {
+			if err := v.add(name.section, name.key, want[i].value, want[i].noValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// edit replaces data between start and end with text, and parses again
+func (v *Document) edit(start, end int, text string) error {
+	data := make([]byte, 0, len(v.data)-(end-start)+len(text))
+	data = append(data, v.data[:start]...)
+	data = append(data, text...)
+	data = append(data, v.data[end:]...)
+	v.data = data
+	return v.reload()
+}
+
+// setValue changes value of the variable at index i, "=" and value are
+// removed if noValue is true.
+func (v *Document) setValue(i int, value string, noValue bool) error {
+	e := v.entries[i]
+	if noValue {
+		return v.edit(e.KeyEnd, e.End, "")
+	}
+	if e.ValueStart < 0 {
+		return v.edit(e.KeyEnd, e.KeyEnd, " = "+quoteValue(value))
+	}
+	start := e.ValueStart
+	for start < e.ValueEnd && isspace(v.data[start]) {
+		start++
+	}
+	if start == e.ValueStart && start == e.ValueEnd {
+		return v.edit(start, e.ValueEnd, " "+quoteValue(value))
+	}
+	return v.edit(start, e.ValueEnd, quoteValue(value))
+}
+
+// lineStart returns offset of the beginning of line which pos is in
+func (v *Document) lineStart(pos int) int {
+	for pos > 0 && v.data[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// lineEnd returns offset of the end of line (after the newline) which pos is in
+func (v *Document) lineEnd(pos int) int {
+	for pos < len(v.data) {
+		pos++
+		if v.data[pos-1] == '\n' {
+			break
+		}
+	}
+	return pos
+}
+
+// remove deletes the variable at index i. The whole line is removed,
+// unless other entries share the same line.
+func (v *Document) remove(i int) error {
+	e := v.entries[i]
+	start := v.lineStart(e.Start)
+	for pos := start; pos < e.Start; pos++ {
+		if !isspace(v.data[pos]) {
+			return v.edit(e.Start, e.End, "")
+		}
+	}
+	end := v.lineEnd(e.End)
+	for pos := e.End; pos < end; pos++ {
+		c := v.data[pos]
+		if c == '#' || c == ';' {
+			break
+		}
+		if !isspace(c) {
+			return v.edit(e.Start, e.End, "")
+		}
+	}
+	return v.edit(start, end, "")
+}
+
+// add inserts a new variable after the last setting of the same key,
+// or at the end of the last block of section, or in a new section at
+// the end of the document.
+func (v *Document) add(section, key, value string, noValue bool) error {
+	if section == "" || key == "" {
+		return ErrInvalidKeyChar
+	}
+
+	last := -1
+	for i, e := range v.entries {
+		s, k := entryKey(e)
+		if s == section && k == key {
+			last = i
+		}
+	}
+	if last < 0 {
+		for i, e := range v.entries {
+			if e.Section == section {
+				last = i
+			}
+		}
+	}
+
+	line := key + " = " + quoteValue(value) + "\n"
+	if noValue {
+		line = key + "\n"
+	}
+	if last < 0 {
+		text := sectionHeader(section) + "\n\t" + line
+		if len(v.data) > 0 && v.data[len(v.data)-1] != '\n' {
+			text = "\n" + text
+		}
+		return v.edit(len(v.data), len(v.data), text)
+	}
+
+	e := v.entries[last]
+	indent := "\t"
+	if !e.IsSection() {
+		start := v.lineStart(e.Start)
+		if strings.TrimSpace(string(v.data[start:e.Start])) == "" {
+			indent = string(v.data[start:e.Start])
+		}
+	}
+	pos := v.lineEnd(e.End)
+	text := indent + line
+	if pos == len(v.data) && (pos == 0 || v.data[pos-1] != '\n') {
+		text = "\n" + text
+	}
+	return v.edit(pos, pos, text)
+}
@@ -0,0 +1,118 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const documentTestData = `# Global settings
+[Core]
+	# keep bare off
+	Bare = false
+	autocrlf=input ; inline comment
+
+[remote "origin"]
+	url = https://example.com/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`
+
+func TestDocumentSet(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := ParseDocument([]byte(documentTestData))
+	assert.Nil(err)
+	assert.Equal([]string{"input"}, doc.GetAll("core.autocrlf"))
+
+	assert.Nil(doc.Set("core.bare", true))
+	assert.Nil(doc.Set("core.autocrlf", "has space "))
+	assert.Equal(`# Global settings
+[Core]
+	# keep bare off
+	Bare = true
+	autocrlf="has space " ; inline comment
+
+[remote "origin"]
+	url = https://example.com/repo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`, doc.String())
+}
+
+func TestDocumentAddUnset(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := ParseDocument([]byte(documentTestData))
+	assert.Nil(err)
+
+	assert.Nil(doc.Add("remote.origin.fetch", "+refs/tags/*:refs/tags/*"))
+	assert.Nil(doc.Add("core.editor", "vi"))
+	assert.Nil(doc.Add("branch.Main.remote", "origin"))
+	assert.Nil(doc.Unset("core.bare"))
+	assert.Nil(doc.UnsetAll("remote.origin.url"))
+	assert.Equal(`# Global settings
+[Core]
+	# keep bare off
+	autocrlf=input ; inline comment
+	editor = vi
+
+[remote "origin"]
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[branch "Main"]
+	remote = origin
+`, doc.String())
+}
+
+func TestDocumentNoNewLine(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := ParseDocument([]byte("[a] b = c"))
+	assert.Nil(err)
+	assert.Nil(doc.Add("a.d", "e"))
+	assert.Nil(doc.Add("f.g", "h"))
+	assert.Equal("[a] b = c\n\td = e\n[f]\n\tg = h\n", doc.String())
+	assert.Nil(doc.Unset("a.b"))
+	assert.Equal("[a] \n\td = e\n[f]\n\tg = h\n", doc.String())
+}
+
+func TestSaveKeepLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	cfgFile := filepath.Join(tmpdir, "config")
+	err = ioutil.WriteFile(cfgFile, []byte(documentTestData), 0644)
+	assert.Nil(err)
+
+	cfg, err := LoadFile(cfgFile)
+	assert.Nil(err)
+	cfg.Set("remote.origin.url", "https://example.com/new.git")
+	cfg.Add("remote.origin.fetch", "+refs/tags/*:refs/tags/*")
+	cfg.Unset("core.autocrlf")
+	cfg.Add("user.name", "Jiang Xin")
+	assert.Nil(cfg.Save(cfgFile))
+
+	data, err := ioutil.ReadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal(`# Global settings
+[Core]
+	# keep bare off
+	Bare = false
+
+[remote "origin"]
+	url = https://example.com/new.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[user]
+	name = Jiang Xin
+`, string(data))
+}
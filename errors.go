@@ -1,6 +1,9 @@
 package gitconfig
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrInvalidKeyChar indicates that there was an invalid key character
 var ErrInvalidKeyChar = errors.New("invalid key character")
@@ -16,3 +19,37 @@ var ErrNotExist = errors.New("config file or dir not exist")
 
 // ErrNotInGitDir indicates not in a git dir
 var ErrNotInGitDir = errors.New("not in a git dir")
+
+// ErrNotSupported indicates the operation is not supported by the file system
+var ErrNotSupported = errors.New("operation not supported by file system")
+
+// ErrReadOnlyFS indicates the file system is not writable
+var ErrReadOnlyFS = errors.New("file system is read-only")
+
+// ErrInvalidUnit indicates a numeric value has an invalid unit suffix
+var ErrInvalidUnit = errors.New("invalid unit")
+
+// ErrOutOfRange indicates a numeric value is out of range
+var ErrOutOfRange = errors.New("out of range")
+
+// ErrRequired indicates a required config variable is missing
+var ErrRequired = errors.New("missing required config")
+
+// ErrMissingValue indicates a config variable has no value (no "="),
+// which is only valid for booleans.
+var ErrMissingValue = errors.New("missing value")
+
+// NumError records a bad numeric config value, Err is ErrInvalidUnit or
+// ErrOutOfRange.
+type NumError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *NumError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("bad numeric config value '%s': %s", e.Value, e.Err)
+	}
+	return fmt.Sprintf("bad numeric config value '%s' for '%s': %s", e.Value, e.Key, e.Err)
+}
@@ -0,0 +1,189 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FS provides access to files for loading config files and finding
+// repositories. It is like fs.FS of Go 1.16, except that names are
+// paths of the OS (mostly absolute paths) as used by this package.
+type FS interface {
+	// Open opens the named file for reading
+	Open(name string) (File, error)
+}
+
+// File is a file opened from FS
+type File interface {
+	Stat() (os.FileInfo, error)
+	Read([]byte) (int, error)
+	Close() error
+}
+
+// StatFS is a FS with Stat method, which is used instead of Open and
+// File.Stat if available.
+type StatFS interface {
+	FS
+	// Stat returns file info of the named file, symbolic links are
+	// followed.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// ReadFileFS is a FS with ReadFile method, which is used instead of
+// Open and File.Read if available.
+type ReadFileFS interface {
+	FS
+	// ReadFile reads the whole named file
+	ReadFile(name string) ([]byte, error)
+}
+
+// ReadDirFS is a FS with ReadDir method, which is used to list worktrees
+// of a repository.
+type ReadDirFS interface {
+	FS
+	// ReadDir reads the named directory, and returns entries sorted by
+	// name.
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// WriteFS is a writable FS, which is required to save config files
+type WriteFS interface {
+	FS
+	// WriteFile writes data to the named file, creating it if necessary
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	// Rename renames oldpath to newpath, and replaces newpath if exists
+	Rename(oldpath, newpath string) error
+	// Remove removes the named file
+	Remove(name string) error
+}
+
+// OSFS is the file system of the OS, which is used by default
+type OSFS struct{}
+
+// Open opens the named file for reading
+func (OSFS) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+// Stat returns file info of the named file
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// ReadFile reads the whole named file
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+// ReadDir reads the named directory
+func (OSFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+// WriteFile writes data to the named file
+func (OSFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(name, data, perm)
+}
+
+// Rename renames oldpath to newpath
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove removes the named file
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+var (
+	fileSystem FS = OSFS{}
+	// fileSystemMutex protects fileSystem
+	fileSystemMutex sync.RWMutex
+)
+
+// SetFS changes the default file system used by functions without an FS
+// argument (e.g. LoadFile, FindRepository and Save) to load and save
+// config files and to find repositories, nil means the file system of
+// the OS. It is meant for process-wide setup before config is loaded:
+// watchers and repositories already created keep the old file system.
+// Use functions with an FS argument (e.g. LoadFileFS, FindRepositoryFS
+// and SaveFS) to serve config from more than one file system.
+func SetFS(fsys FS) {
+	if fsys == nil {
+		fsys = OSFS{}
+	}
+	fileSystemMutex.Lock()
+	fileSystem = fsys
+	fileSystemMutex.Unlock()
+}
+
+// GetFS returns the default file system
+func GetFS() FS {
+	fileSystemMutex.RLock()
+	defer fileSystemMutex.RUnlock()
+	return fileSystem
+}
+
+// isOSFS checks whether fsys is the file system of the OS
+func isOSFS(fsys FS) bool {
+	_, ok := fsys.(OSFS)
+	return ok
+}
+
+// openFile opens file from fsys
+func openFile(fsys FS, name string) (File, error) {
+	return fsys.Open(name)
+}
+
+// statFile returns file info from fsys
+func statFile(fsys FS, name string) (os.FileInfo, error) {
+	if fsys, ok := fsys.(StatFS); ok {
+		return fsys.Stat(name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// readFile reads the whole file from fsys
+func readFile(fsys FS, name string) ([]byte, error) {
+	if fsys, ok := fsys.(ReadFileFS); ok {
+		return fsys.ReadFile(name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// readDir reads directory from fsys
+func readDir(fsys FS, name string) ([]os.FileInfo, error) {
+	if fsys, ok := fsys.(ReadDirFS); ok {
+		return fsys.ReadDir(name)
+	}
+	return nil, &os.PathError{Op: "readdir", Path: name, Err: ErrNotSupported}
+}
+
+// writableFS returns fsys if it is writable
+func writableFS(fsys FS) (WriteFS, error) {
+	if fsys, ok := fsys.(WriteFS); ok {
+		return fsys, nil
+	}
+	return nil, ErrReadOnlyFS
+}
+
+// evalSymlinks returns path with symbolic links resolved, which is only
+// supported by the file system of the OS.
+func evalSymlinks(fsys FS, name string) (string, error) {
+	if !isOSFS(fsys) {
+		return name, nil
+	}
+	return filepath.EvalSymlinks(name)
+}
@@ -0,0 +1,277 @@
+package gitconfig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memFS is an in-memory file system for test
+type memFS struct {
+	mutex   sync.Mutex
+	files   map[string][]byte
+	dirs    map[string]bool
+	modTime map[string]time.Time
+	counter int64
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (v memFileInfo) Name() string       { return filepath.Base(v.name) }
+func (v memFileInfo) Size() int64        { return v.size }
+func (v memFileInfo) ModTime() time.Time { return v.modTime }
+func (v memFileInfo) IsDir() bool        { return v.dir }
+func (v memFileInfo) Sys() interface{}   { return nil }
+func (v memFileInfo) Mode() os.FileMode {
+	if v.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (v memFile) Stat() (os.FileInfo, error) { return v.info, nil }
+func (v memFile) Close() error               { return nil }
+
+func newMemFS(files map[string]string) *memFS {
+	fsys := &memFS{
+		files:   make(map[string][]byte),
+		dirs:    make(map[string]bool),
+		modTime: make(map[string]time.Time),
+	}
+	for name, data := range files {
+		if strings.HasSuffix(name, "/") {
+			fsys.addDir(strings.TrimSuffix(name, "/"))
+			continue
+		}
+		fsys.WriteFile(name, []byte(data), 0644)
+	}
+	return fsys
+}
+
+func (v *memFS) addDir(name string) {
+	for name != filepath.Dir(name) {
+		v.dirs[name] = true
+		name = filepath.Dir(name)
+	}
+}
+
+func (v *memFS) notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (v *memFS) Open(name string) (File, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if data, ok := v.files[name]; ok {
+		return memFile{
+			Reader: bytes.NewReader(data),
+			info: memFileInfo{
+				name:    name,
+				size:    int64(len(data)),
+				modTime: v.modTime[name],
+			},
+		}, nil
+	}
+	if v.dirs[name] || name == "/" {
+		return memFile{
+			Reader: bytes.NewReader(nil),
+			info:   memFileInfo{name: name, dir: true},
+		}, nil
+	}
+	return nil, v.notExist("open", name)
+}
+
+func (v *memFS) ReadDir(name string) ([]os.FileInfo, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if !v.dirs[name] {
+		return nil, v.notExist("readdir", name)
+	}
+	result := []os.FileInfo{}
+	for dir := range v.dirs {
+		if filepath.Dir(dir) == name {
+			result = append(result, memFileInfo{name: dir, dir: true})
+		}
+	}
+	for file, data := range v.files {
+		if filepath.Dir(file) == name {
+			result = append(result, memFileInfo{name: file, size: int64(len(data))})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+func (v *memFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.counter++
+	v.files[name] = append([]byte(nil), data...)
+	v.modTime[name] = time.Unix(v.counter, 0)
+	v.addDir(filepath.Dir(name))
+	return nil
+}
+
+func (v *memFS) Rename(oldpath, newpath string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	data, ok := v.files[oldpath]
+	if !ok {
+		return v.notExist("rename", oldpath)
+	}
+	v.files[newpath] = data
+	v.modTime[newpath] = v.modTime[oldpath]
+	delete(v.files, oldpath)
+	delete(v.modTime, oldpath)
+	return nil
+}
+
+func (v *memFS) Remove(name string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.files[name]; !ok {
+		return v.notExist("remove", name)
+	}
+	delete(v.files, name)
+	delete(v.modTime, name)
+	return nil
+}
+
+// readOnlyFS only implements Open of FS
+type readOnlyFS struct {
+	fsys FS
+}
+
+func (v readOnlyFS) Open(name string) (File, error) {
+	return v.fsys.Open(name)
+}
+
+func TestMemFS(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := newMemFS(map[string]string{
+		"/repo/.git/HEAD":     "ref: refs/heads/main\n",
+		"/repo/.git/config":   "[core]\n\tbare = false\n[include]\n\tpath = extra\n",
+		"/repo/.git/extra":    "[test]\n\tkey = extra\n",
+		"/repo/.git/refs/":    "",
+		"/repo/.git/objects/": "",
+		"/repo/src/":          "",
+	})
+	SetFS(fsys)
+	defer SetFS(nil)
+
+	configFile, err := FindGitConfig("/repo/src")
+	assert.Nil(err)
+	assert.Equal("/repo/.git/config", configFile)
+	configFile, err = FindWorktreeConfig("/repo")
+	assert.Nil(err)
+	assert.Equal("/repo/.git/config", configFile)
+
+	cfg, err := LoadDir("/repo/src")
+	assert.Nil(err)
+	assert.Equal("extra", cfg.Get("test.key"))
+	assert.Equal("false", cfg.Get("core.bare"))
+
+	cfg.Set("core.bare", true)
+	cfg.Set("user.name", "Jiang Xin")
+	assert.Nil(cfg.Save(configFile))
+	assert.Equal("[core]\n\tbare = true\n[include]\n\tpath = extra\n[user]\n\tname = Jiang Xin\n",
+		string(fsys.files[configFile]))
+	_, ok := fsys.files[configFile+".lock"]
+	assert.False(ok)
+
+	cfg, err = LoadDir("/repo")
+	assert.Nil(err)
+	assert.Equal("true", cfg.Get("core.bare"))
+	assert.Equal("Jiang Xin", cfg.Get("user.name"))
+	assert.Equal("extra", cfg.Get("test.key"))
+
+	// Not in a repository
+	_, err = LoadDir("/")
+	assert.Equal(ErrNotExist, err)
+
+	// Files are loaded using Open only, and cannot be saved
+	SetFS(readOnlyFS{fsys})
+	cfg, err = LoadFile(configFile)
+	assert.Nil(err)
+	assert.Equal("true", cfg.Get("core.bare"))
+	assert.Equal("extra", cfg.Get("test.key"))
+	assert.True(IsDir("/repo/.git"))
+	assert.True(IsFile("/repo/.git/HEAD"))
+	assert.False(Exist("/repo/.git/missing"))
+	assert.Equal(ErrReadOnlyFS, cfg.Save(configFile))
+}
+
+func TestMultipleFS(t *testing.T) {
+	assert := assert.New(t)
+
+	newRepoFS := func(name string) *memFS {
+		return newMemFS(map[string]string{
+			"/repo/.git/HEAD":     "ref: refs/heads/main\n",
+			"/repo/.git/config":   "[include]\n\tpath = extra\n",
+			"/repo/.git/extra":    "[user]\n\tname = " + name + "\n",
+			"/repo/.git/refs/":    "",
+			"/repo/.git/objects/": "",
+		})
+	}
+	fs1 := newRepoFS("one")
+	fs2 := newRepoFS("two")
+
+	// Files of the same name in different file systems are cached
+	// separately, and the default file system is not used.
+	for i := 0; i < 2; i++ {
+		cfg, err := LoadFileFS(fs1, "/repo/.git/config")
+		assert.Nil(err)
+		assert.Equal("one", cfg.Get("user.name"))
+		cfg, err = LoadFileFS(fs2, "/repo/.git/config")
+		assert.Nil(err)
+		assert.Equal("two", cfg.Get("user.name"))
+	}
+	_, err := LoadFile("/repo/.git/config")
+	assert.Equal(ErrNotExist, err)
+
+	cfg, err := LoadDirFS(fs2, "/repo")
+	assert.Nil(err)
+	assert.Equal("two", cfg.Get("user.name"))
+
+	repo, err := FindRepositoryFS(fs1, "/repo")
+	if assert.Nil(err) {
+		assert.Equal("one", repo.Config().Get("user.name"))
+		assert.Equal("/repo", repo.WorkDir())
+	}
+
+	// Included file is changed in one file system only
+	cfg = NewGitConfig()
+	cfg.Set("user.name", "new")
+	assert.Nil(cfg.SaveFS(fs2, "/repo/.git/extra"))
+	cfg, err = LoadFileFS(fs2, "/repo/.git/config")
+	assert.Nil(err)
+	assert.Equal("new", cfg.Get("user.name"))
+	cfg, err = LoadFileFS(fs1, "/repo/.git/config")
+	assert.Nil(err)
+	assert.Equal("one", cfg.Get("user.name"))
+}
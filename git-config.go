@@ -2,9 +2,8 @@ package gitconfig
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,6 +22,8 @@ const (
 	ScopeSystem
 	ScopeGlobal
 	ScopeSelf
+	ScopeCommand
+	ScopeWorktree
 
 	ScopeAll  scope = 0xFFFF
 	ScopeMask scope = ^ScopeInclude
@@ -80,6 +81,10 @@ func (v *scope) String() string {
 		return "global" + inc
 	} else if (*v & ScopeSelf) == ScopeSelf {
 		return "self" + inc
+	} else if (*v & ScopeCommand) == ScopeCommand {
+		return "command" + inc
+	} else if (*v & ScopeWorktree) == ScopeWorktree {
+		return "worktree" + inc
 	}
 	return "unknown" + inc
 }
@@ -90,10 +95,13 @@ type GitConfig map[string]gitConfigKeyValues
 // gitConfigKeyValues maps key to values
 type gitConfigKeyValues map[string][]gitConfigValue
 
-// gitConfigValue holds value and its scope
+// gitConfigValue holds value, its scope and origin
 type gitConfigValue struct {
-	scope scope
-	value string
+	scope  scope
+	value  string
+	origin Origin
+	// noValue is true for a variable without "=", which is true as boolean
+	noValue bool
 }
 
 // Keys returns sorted keys in one section
@@ -120,6 +128,11 @@ func (v gitConfigValue) Value() string {
 	return v.value
 }
 
+// isSelf indicates value is defined in the config file itself
+func (v gitConfigValue) isSelf() bool {
+	return (v.scope&ScopeInclude) == 0 && (v.scope&ScopeSelf) != 0
+}
+
 // Scope is used to show user friendly scope
 func (v gitConfigValue) Scope() string {
 	return v.scope.String()
@@ -131,6 +144,22 @@ func NewGitConfig() GitConfig {
 	return c
 }
 
+// Clone returns a deep copy of GitConfig
+func (v GitConfig) Clone() GitConfig {
+	if v == nil {
+		return nil
+	}
+	c := make(GitConfig, len(v))
+	for s, keys := range v {
+		c[s] = make(gitConfigKeyValues, len(keys))
+		for k, values := range keys {
+			c[s][k] = make([]gitConfigValue, len(values))
+			copy(c[s][k], values)
+		}
+	}
+	return c
+}
+
 // Sections returns sorted sections
 func (v GitConfig) Sections() []string {
 	keys := []string{}
@@ -182,6 +211,7 @@ func (v GitConfig) Set(key string, value interface{}) {
 		if keys[k][i].scope == ScopeSelf {
 			found = true
 			keys[k][i].value = toString(value)
+			keys[k][i].noValue = false
 			break
 		}
 	}
@@ -234,6 +264,11 @@ func (v GitConfig) Add(key string, value ...interface{}) {
 
 // _add key/value to config variables
 func (v GitConfig) _add(section, key string, value ...interface{}) {
+	v.addWithOrigin(section, key, Origin{}, value...)
+}
+
+// addWithOrigin adds key/value to config variables with origin
+func (v GitConfig) addWithOrigin(section, key string, origin Origin, value ...interface{}) {
 	// section, and key are always in lower case
 	if _, ok := v[section]; !ok {
 		v[section] = make(gitConfigKeyValues)
@@ -245,12 +280,20 @@ func (v GitConfig) _add(section, key string, value ...interface{}) {
 	for _, val := range value {
 		v[section][key] = append(v[section][key],
 			gitConfigValue{
-				scope: ScopeSelf,
-				value: toString(val),
+				scope:  ScopeSelf,
+				value:  toString(val),
+				origin: origin,
 			})
 	}
 }
 
+// addNoValue adds a variable without value, such as "[core] bare"
+func (v GitConfig) addNoValue(section, key string, origin Origin) {
+	v.addWithOrigin(section, key, origin, "")
+	values := v[section][key]
+	values[len(values)-1].noValue = true
+}
+
 // Get value from key
 func (v GitConfig) Get(key string) string {
 	values := v.GetAll(key)
@@ -269,22 +312,99 @@ func (v GitConfig) GetBool(key string, defaultValue bool) bool {
 	return result
 }
 
-// GetBoolE gets boolean from key with default value with error
+// GetBoolE gets boolean from key with default value with error. A
+// variable without value (e.g. "[core] bare") is true, and an empty
+// value is false, the same as git.
 func (v GitConfig) GetBoolE(key string, defaultValue bool) (bool, error) {
-	value := v.Get(key)
-	if value == "" {
+	values := v.getRaw(key)
+	if len(values) == 0 {
 		return defaultValue, nil
 	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return true, nil
+	}
+	return ParseBool(value.value)
+}
 
+// parseBoolText converts "yes", "true", "on", "no", "false", "off" and
+// empty string to boolean, the same as git_parse_maybe_bool_text().
+func parseBoolText(value string) (bool, bool) {
 	switch strings.ToLower(value) {
 	case "yes", "true", "on":
-		return true, nil
-	case "no", "false", "off":
-		return false, nil
+		return true, true
+	case "no", "false", "off", "":
+		return false, true
+	}
+	return false, false
+}
+
+// ParseBool converts string to boolean the same as git, integers are
+// also booleans: zero is false, and others are true.
+func ParseBool(value string) (bool, error) {
+	if result, ok := parseBoolText(value); ok {
+		return result, nil
+	}
+	if result, err := ParseInt(value); err == nil {
+		return result != 0, nil
 	}
 	return false, ErrNotBoolValue
 }
 
+// ParseBoolOrInt converts boolean string to 1 or 0 and isBool is true,
+// or parses value as an integer. Error is a *NumError.
+func ParseBoolOrInt(value string) (result int, isBool bool, err error) {
+	if b, ok := parseBoolText(value); ok {
+		if b {
+			return 1, true, nil
+		}
+		return 0, true, nil
+	}
+	result, err = ParseInt(value)
+	return result, false, err
+}
+
+// GetBoolOrInt gets boolean (as 1 or 0) or integer from key with default
+func (v GitConfig) GetBoolOrInt(key string, defaultValue int) int {
+	result, err := v.GetBoolOrIntE(key, defaultValue)
+	if err != nil {
+		result = defaultValue
+	}
+	return result
+}
+
+// GetBoolOrIntE gets boolean (as 1 or 0) or integer from key with
+// default with error, such as "core.sharedRepository".
+func (v GitConfig) GetBoolOrIntE(key string, defaultValue int) (int, error) {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return 1, nil
+	}
+	result, _, err := ParseBoolOrInt(value.value)
+	return result, numError(key, err)
+}
+
+// GetBoolOrStr gets "true" or "false" if value is a boolean, or the
+// value itself, such as "pull.ff" which may be "only".
+func (v GitConfig) GetBoolOrStr(key string, defaultValue string) string {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return "true"
+	}
+	if result, err := ParseBool(value.value); err == nil {
+		return strconv.FormatBool(result)
+	}
+	return value.value
+}
+
 // GetInt return integer value of key with default
 func (v GitConfig) GetInt(key string, defaultValue int) int {
 	result, err := v.GetIntE(key, defaultValue)
@@ -294,14 +414,24 @@ func (v GitConfig) GetInt(key string, defaultValue int) int {
 	return result
 }
 
-// GetIntE return integer value of key with default with error
+// GetIntE return integer value of key with default with error, value
+// may have unit suffix "k", "m" or "g" the same as git. A variable
+// without value is ErrMissingValue.
 func (v GitConfig) GetIntE(key string, defaultValue int) (int, error) {
-	value := v.Get(key)
-	if value == "" {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return 0, ErrMissingValue
+	}
+	if value.value == "" {
 		return defaultValue, nil
 	}
 
-	return strconv.Atoi(value)
+	result, err := ParseInt(value.value)
+	return result, numError(key, err)
 }
 
 // GetInt64 return int64 value of key with default
@@ -315,12 +445,20 @@ func (v GitConfig) GetInt64(key string, defaultValue int64) int64 {
 
 // GetInt64E return int64 value of key with default with error
 func (v GitConfig) GetInt64E(key string, defaultValue int64) (int64, error) {
-	value := v.Get(key)
-	if value == "" {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return 0, ErrMissingValue
+	}
+	if value.value == "" {
 		return defaultValue, nil
 	}
 
-	return strconv.ParseInt(value, 10, 64)
+	result, err := ParseInt64(value.value)
+	return result, numError(key, err)
 }
 
 // GetUint64 return uint64 value of key with default
@@ -334,12 +472,20 @@ func (v GitConfig) GetUint64(key string, defaultValue uint64) uint64 {
 
 // GetUint64E return uint64 value of key with default with error
 func (v GitConfig) GetUint64E(key string, defaultValue uint64) (uint64, error) {
-	value := v.Get(key)
-	if value == "" {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return 0, ErrMissingValue
+	}
+	if value.value == "" {
 		return defaultValue, nil
 	}
 
-	return strconv.ParseUint(value, 10, 64)
+	result, err := ParseUint64(value.value)
+	return result, numError(key, err)
 }
 
 // GetAll gets all values of a key
@@ -396,60 +542,109 @@ func toSectionKey(name string) (string, string) {
 
 // Parse takes given bytes as configuration file (according to gitconfig syntax)
 func Parse(bytes []byte, filename string) (GitConfig, uint, error) {
-	var (
-		gitCfg = NewGitConfig()
-		line   uint
-		err    error
-		depth  uint
-	)
-
-	for {
-		var (
-			cfg         = NewGitConfig()
-			file        string
-			gocfg       map[string][]string
-			includePath string
-		)
-
-		gocfg, line, err = goconfig.Parse(bytes)
-		for key, val := range gocfg {
-			for _, item := range val {
-				cfg.Add(key, item)
-			}
+	origin := Origin{}
+	if filename != "" {
+		origin = Origin{Type: OriginFile, Name: filename}
+	}
+	return parse(GetFS(), bytes, origin, nil, 0, nil)
+}
+
+// parse config from origin (a file or a blob), and expands included
+// files in fsys at the position of include directives, conditional
+// includes are evaluated using ctx. The depth of include chain is
+// checked to detect circular includes. States of included files are recorded in files for
+// cache validation.
+func parse(fsys FS, bytes []byte, origin Origin, ctx *includeContext, depth int, files *fileStats) (GitConfig, uint, error) {
+	cfg := NewGitConfig()
+	filename := ""
+	if origin.Type == OriginFile {
+		filename = origin.Name
+	}
+
+	entries, line, err := goconfig.ParseEntries(bytes)
+	for _, e := range entries {
+		if e.IsSection() {
+			continue
 		}
-		if depth == 0 {
-			gitCfg = cfg
-		} else {
-			gitCfg = gitCfg.Merge(cfg, ScopeInclude)
+		cfg.addEntry(e, origin)
+
+		section, key := entryKey(e)
+		if key != "path" || e.Value == "" {
+			continue
 		}
-		includePath = cfg.Get("include.path")
-		if includePath == "" {
-			break
+		if section != "include" {
+			if !strings.HasPrefix(section, "includeif.") ||
+				!ctx.match(strings.TrimPrefix(section, "includeif."), filename) {
+				continue
+			}
 		}
-		file, err = absJoin(path.Dir(filename), includePath)
-		if err != nil {
-			break
+		if includeErr := cfg.include(fsys, e.Value, origin, ctx, depth, files); includeErr != nil {
+			return cfg, e.Line, includeErr
 		}
-		depth++
-		// Check circular includes
-		if depth >= maxIncludeDepth {
-			err = fmt.Errorf("exceeded maximum include depth (%d) while including\n"+
-				"\t%s\n"+
-				"from"+
-				"\t%s\n"+
-				"This might be due to circular includes\n",
-				maxIncludeDepth,
-				filename,
-				file)
-			break
+	}
+	return cfg, line, err
+}
+
+// parseWithoutIncludes parses config without processing include.path
+// and includeIf, such as ".gitmodules" which git never includes files.
+func parseWithoutIncludes(bytes []byte, origin Origin) (GitConfig, uint, error) {
+	cfg := NewGitConfig()
+	entries, line, err := goconfig.ParseEntries(bytes)
+	for _, e := range entries {
+		if !e.IsSection() {
+			cfg.addEntry(e, origin)
 		}
-		filename = file
-		bytes, err = ioutil.ReadFile(file)
-		if err != nil {
-			break
+	}
+	return cfg, line, err
+}
+
+// addEntry adds variable of entry parsed from origin
+func (v GitConfig) addEntry(e goconfig.Entry, origin Origin) {
+	s, k := toSectionKey(e.Key)
+	origin = Origin{Type: origin.Type, Name: origin.Name, Line: e.Line}
+	if e.NoValue {
+		v.addNoValue(s, k, origin)
+	} else {
+		v.addWithOrigin(s, k, origin, e.Value)
+	}
+}
+
+// include parses included file in fsys and merges its values into config,
+// included files (even missing ones) are recorded in files. Relative
+// path is not allowed to be included from blob, the same as git.
+func (v GitConfig) include(fsys FS, includePath string, origin Origin, ctx *includeContext, depth int, files *fileStats) error {
+	filename := origin.Name
+	if origin.Type == OriginBlob && !filepath.IsAbs(includePath) &&
+		includePath[0] != '~' {
+		return fmt.Errorf("relative config includes must come from files")
+	}
+	file, err := absJoin(filepath.Dir(filename), includePath)
+	if err != nil {
+		return err
+	}
+	// Check circular includes
+	if depth+1 >= maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including\n"+
+			"\t%s\n"+
+			"from"+
+			"\t%s\n"+
+			"This might be due to circular includes\n",
+			maxIncludeDepth,
+			filename,
+			file)
+	}
+	files.add(fsys, file)
+	bytes, err := readFile(fsys, file)
+	if err != nil {
+		// Missing included file is ignored, the same as git
+		if os.IsNotExist(err) {
+			return nil
 		}
+		return err
 	}
-	return gitCfg, line, err
+	includeCfg, _, err := parse(fsys, bytes, Origin{Type: OriginFile, Name: file}, ctx, depth+1, files)
+	v.Merge(includeCfg, ScopeInclude)
+	return err
 }
 
 // Merge will merge another GitConfig, and new value(s) of the same key will
@@ -466,8 +661,10 @@ func (v GitConfig) Merge(c GitConfig, scope scope) GitConfig {
 			for _, value := range values {
 				v[sec][key] = append(v[sec][key],
 					gitConfigValue{
-						scope: (value.scope & ^ScopeMask) | scope,
-						value: value.Value(),
+						scope:   (value.scope & ^ScopeMask) | scope,
+						value:   value.Value(),
+						origin:  value.origin,
+						noValue: value.noValue,
 					})
 
 			}
@@ -491,11 +688,6 @@ func (v GitConfig) stringOfScope(scope scope) string {
 	}
 
 	for _, s := range v.Sections() {
-		secs := strings.SplitN(s, ".", 2)
-		sec := s
-		if len(secs) == 2 {
-			sec = fmt.Sprintf("%s \"%s\"", secs[0], secs[1])
-		}
 		once := true
 		for _, k := range v[s].Keys() {
 			for _, value := range v[s][k] {
@@ -508,40 +700,13 @@ func (v GitConfig) stringOfScope(scope scope) string {
 
 				if once {
 					once = false
-					lines = append(lines, "["+sec+"]")
-				}
-				line := "\t" + k + " = "
-				quote := false
-				if len(value.value) > 0 &&
-					(isspace(value.value[0]) || isspace(value.value[len(value.value)-1])) {
-					quote = true
-				}
-				if quote {
-					line += "\""
-				}
-				for _, c := range value.value {
-					switch c {
-					case '\n':
-						line += "\\n"
-						continue
-					case '\t':
-						line += "\\t"
-						continue
-					case '\b':
-						line += "\\b"
-						continue
-					case '\\':
-						line += "\\"
-					case '"':
-						line += "\\"
-					}
-					line += string(c)
+					lines = append(lines, sectionHeader(s))
 				}
-				if quote {
-					line += "\""
+				if value.noValue {
+					lines = append(lines, "\t"+k)
+				} else {
+					lines = append(lines, "\t"+k+" = "+quoteValue(value.value))
 				}
-
-				lines = append(lines, line)
 			}
 		}
 
@@ -549,29 +714,99 @@ func (v GitConfig) stringOfScope(scope scope) string {
 	return strings.Join(lines, "\n") + "\n"
 }
 
+// sectionHeader returns section header line, such as: [remote "origin"]
+func sectionHeader(section string) string {
+	secs := strings.SplitN(section, ".", 2)
+	if len(secs) != 2 {
+		return "[" + section + "]"
+	}
+	sub := strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(secs[1])
+	return fmt.Sprintf("[%s \"%s\"]", secs[0], sub)
+}
+
+// quoteValue escapes value, and quotes it if necessary
+func quoteValue(value string) string {
+	line := ""
+	quote := false
+	if len(value) > 0 &&
+		(isspace(value[0]) || isspace(value[len(value)-1])) {
+		quote = true
+	}
+	if strings.ContainsAny(value, "#;") {
+		quote = true
+	}
+	if quote {
+		line += "\""
+	}
+	for _, c := range value {
+		switch c {
+		case '\n':
+			line += "\\n"
+			continue
+		case '\t':
+			line += "\\t"
+			continue
+		case '\b':
+			line += "\\b"
+			continue
+		case '\\':
+			line += "\\"
+		case '"':
+			line += "\\"
+		}
+		line += string(c)
+	}
+	if quote {
+		line += "\""
+	}
+	return line
+}
+
 func isspace(c byte) bool {
 	return c == '\t' || c == ' ' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
 }
 
-// Save will save git config to file
+// Save will save git config to file. Contents of an existing file are
+// edited in place, so comments and layout of untouched lines are kept.
 func (v GitConfig) Save(file string) error {
+	return v.SaveFS(GetFS(), file)
+}
+
+// SaveFS saves git config to file in fsys, like Save
+func (v GitConfig) SaveFS(fsys FS, file string) error {
 	if file == "" {
 		return fmt.Errorf("cannot save config, unknown filename")
 	}
 
+	wfsys, err := writableFS(fsys)
+	if err != nil {
+		return err
+	}
+	data, err := readFile(fsys, file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return fmt.Errorf("fail to parse '%s': %s", file, err)
+	}
+	if err = doc.update(v); err != nil {
+		return err
+	}
+
 	lockFile := file + ".lock"
 
-	err := ioutil.WriteFile(lockFile, []byte(v.String()), 0644)
-	defer os.Remove(lockFile)
+	err = wfsys.WriteFile(lockFile, doc.Bytes(), 0644)
+	defer wfsys.Remove(lockFile)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = LoadFile(lockFile)
+	_, err = loadFile(fsys, lockFile, nil, nil)
 	if err != nil {
 		return fmt.Errorf("fail to save '%s': %s", file, err)
 	}
 
-	return os.Rename(lockFile, file)
+	return wfsys.Rename(lockFile, file)
 }
@@ -2,6 +2,10 @@ package gitconfig
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -167,12 +171,11 @@ func TestGetBool(t *testing.T) {
 	v = cfg.GetBool("a.f4", true)
 	assert.True(v)
 
-	v, err = cfg.GetBoolE("a.x1", true)
-	assert.Equal(ErrNotBoolValue, err)
-	v = cfg.GetBool("a.x1", true)
+	v, err = cfg.GetBoolE("a.x1", false)
+	assert.Nil(err)
 	assert.True(v)
 	v = cfg.GetBool("a.x1", false)
-	assert.False(v)
+	assert.True(v)
 
 	v, err = cfg.GetBoolE("a.x2", true)
 	assert.Equal(ErrNotBoolValue, err)
@@ -182,13 +185,130 @@ func TestGetBool(t *testing.T) {
 	assert.False(v)
 }
 
+func TestGitBoolSemantics(t *testing.T) {
+	assert := assert.New(t)
+
+	data := `[a]
+	novalue
+	empty =
+	quoted = ""
+	zero = 0
+	two = 2
+	unit = 1k
+	hex = 0x0
+	on = On
+	str = only
+[b]
+	novalue = false
+	novalue
+[c]
+	novalue
+	novalue = false`
+
+	cfg, _, err := Parse([]byte(data), "filename")
+	assert.Nil(err)
+
+	for _, tc := range []struct {
+		Key    string
+		Result bool
+	}{
+		{"a.novalue", true},
+		{"a.empty", false},
+		{"a.quoted", false},
+		{"a.zero", false},
+		{"a.two", true},
+		{"a.unit", true},
+		{"a.hex", false},
+		{"a.on", true},
+		{"b.novalue", true},
+		{"c.novalue", false},
+	} {
+		v, err := cfg.GetBoolE(tc.Key, !tc.Result)
+		assert.Nil(err, tc.Key)
+		assert.Equal(tc.Result, v, tc.Key)
+	}
+	_, err = cfg.GetBoolE("a.str", false)
+	assert.Equal(ErrNotBoolValue, err)
+	for _, value := range []string{"k", "-k", "+g"} {
+		_, err = ParseBool(value)
+		assert.Equal(ErrNotBoolValue, err, value)
+	}
+	v, err := cfg.GetBoolE("a.missing", true)
+	assert.Nil(err)
+	assert.True(v)
+
+	// Value without "=" is different from empty value
+	assert.Equal("", cfg.Get("a.novalue"))
+	value, ok := cfg.GetWithOrigin("a.novalue")
+	assert.True(ok)
+	assert.True(value.NoValue)
+	value, ok = cfg.GetWithOrigin("a.empty")
+	assert.True(ok)
+	assert.False(value.NoValue)
+
+	for _, tc := range []struct {
+		Key    string
+		Result int
+	}{
+		{"a.novalue", 1},
+		{"a.empty", 0},
+		{"a.zero", 0},
+		{"a.two", 2},
+		{"a.unit", 1024},
+		{"a.on", 1},
+		{"a.missing", 7},
+	} {
+		v, err := cfg.GetBoolOrIntE(tc.Key, 7)
+		assert.Nil(err, tc.Key)
+		assert.Equal(tc.Result, v, tc.Key)
+	}
+	_, err = cfg.GetBoolOrIntE("a.str", 0)
+	assert.Equal(&NumError{Key: "a.str", Value: "only", Err: ErrInvalidUnit}, err)
+	assert.Equal(3, cfg.GetBoolOrInt("a.str", 3))
+
+	for _, tc := range []struct {
+		Key    string
+		Result string
+	}{
+		{"a.novalue", "true"},
+		{"a.empty", "false"},
+		{"a.two", "true"},
+		{"a.on", "true"},
+		{"a.str", "only"},
+		{"a.missing", "default"},
+	} {
+		assert.Equal(tc.Result, cfg.GetBoolOrStr(tc.Key, "default"), tc.Key)
+	}
+
+	// Variables without value are kept when saving
+	assert.True(strings.Contains(cfg.String(), "\tnovalue\n"))
+	assert.True(strings.Contains(cfg.String(), "\tempty = \n"))
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if !assert.Nil(err) {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+	filename := filepath.Join(tmpdir, "config")
+	assert.Nil(ioutil.WriteFile(filename, []byte("[a]\n\tnovalue\n\tempty =\n\tx = 1\n"), 0644))
+	cfg, err = LoadFile(filename)
+	assert.Nil(err)
+	cfg.Set("a.x", 2)
+	cfg.Set("a.empty", "yes")
+	assert.Nil(cfg.Save(filename))
+	saved, err := ioutil.ReadFile(filename)
+	assert.Nil(err)
+	assert.Equal("[a]\n\tnovalue\n\tempty = yes\n\tx = 2\n", string(saved))
+}
+
 func TestGetInt(t *testing.T) {
 	assert := assert.New(t)
 
 	data := `[a]
 	i1 = 1
 	i2 = 100
-	i3 = abc`
+	i3 = abc
+	novalue`
 
 	cfg, _, err := Parse([]byte(data), "filename")
 	assert.Nil(err)
@@ -219,6 +339,15 @@ func TestGetInt(t *testing.T) {
 	assert.Equal(6700, v4)
 	v4 = cfg.GetInt("a.i4", 6700)
 	assert.Equal(6700, v4)
+
+	// Variable without value is not a number
+	_, err = cfg.GetIntE("a.novalue", 6700)
+	assert.Equal(ErrMissingValue, err)
+	_, err = cfg.GetInt64E("a.novalue", 6700)
+	assert.Equal(ErrMissingValue, err)
+	_, err = cfg.GetUint64E("a.novalue", 6700)
+	assert.Equal(ErrMissingValue, err)
+	assert.Equal(6700, cfg.GetInt("a.novalue", 6700))
 }
 
 func TestMerge(t *testing.T) {
@@ -259,7 +388,7 @@ func TestScope(t *testing.T) {
 	assert.Equal(scope(0xFFFE), ScopeMask)
 }
 
-func ExampleMerge() {
+func ExampleGitConfig_Merge() {
 	sys := NewGitConfig()
 	sys.Add("sect1.Name1", "value-1.1.1")
 	sys.Add("sect1.Name2", "value-1.1.2")
@@ -1,20 +1,76 @@
 package goconfig
 
+import "strings"
+
 const utf8BOM = "\357\273\277"
 
 type parser struct {
-	bytes  []byte
-	linenr uint
-	eof    bool
+	bytes   []byte
+	size    int
+	prev    int
+	linenr  uint
+	eof     bool
+	entries []Entry
+}
+
+// Entry is a section header or a variable found in a config file, together
+// with the offsets of the text it was parsed from.
+type Entry struct {
+	// Section is the section name, like "core" or "remote.origin"
+	Section string
+	// Key is the full name of the variable, empty for a section header
+	Key string
+	// Value is the unquoted value of the variable
+	Value string
+	// NoValue is true if there is no "=" after the name (e.g. "[core] bare"),
+	// which is different from an empty value.
+	NoValue bool
+	// Line is the line number where the entry starts
+	Line uint
+	// Start and End are offsets of the whole entry
+	Start int
+	End   int
+	// KeyEnd is the offset where the name of the variable ends
+	KeyEnd int
+	// ValueStart and ValueEnd are offsets of the raw value after "=",
+	// ValueStart is -1 if there is no "=".
+	ValueStart int
+	ValueEnd   int
+}
+
+// IsSection indicates the entry is a section header
+func (e Entry) IsSection() bool {
+	return e.Key == ""
+}
+
+func newParser(bytes []byte) *parser {
+	return &parser{
+		bytes:  bytes,
+		size:   len(bytes),
+		linenr: 1,
+	}
 }
 
 // Parse takes given bytes as configuration file (according to gitconfig syntax)
 func Parse(bytes []byte) (map[string][]string, uint, error) {
-	parser := &parser{bytes, 1, false}
+	parser := newParser(bytes)
 	cfg, err := parser.parse()
 	return cfg, parser.linenr, err
 }
 
+// ParseEntries parses given bytes like Parse, but returns section headers
+// and variables in the order they appear, with their positions.
+func ParseEntries(bytes []byte) ([]Entry, uint, error) {
+	parser := newParser(bytes)
+	_, err := parser.parse()
+	return parser.entries, parser.linenr, err
+}
+
+// offset returns the offset of the next char to read
+func (cf *parser) offset() int {
+	return cf.size - len(cf.bytes)
+}
+
 func (cf *parser) parse() (map[string][]string, error) {
 	bomPtr := 0
 	comment := false
@@ -50,21 +106,31 @@ func (cf *parser) parse() (map[string][]string, error) {
 			continue
 		}
 		if c == '[' {
+			entry := Entry{Line: cf.linenr, Start: cf.prev, ValueStart: -1}
 			name, err = cf.getSectionKey()
 			if err != nil {
 				return cfg, err
 			}
+			entry.Section = name
+			entry.End = cf.offset()
+			entry.KeyEnd = entry.End
+			cf.entries = append(cf.entries, entry)
 			name += "."
 			continue
 		}
 		if !isalpha(c) {
 			return cfg, ErrInvalidKeyChar
 		}
+		entry := Entry{Line: cf.linenr, Start: cf.prev}
 		key := name + string(c)
-		value, err := cf.getValue(&key)
+		value, err := cf.getValue(&key, &entry)
 		if err != nil {
 			return cfg, err
 		}
+		entry.Section = strings.TrimSuffix(name, ".")
+		entry.Key = key
+		entry.Value = value
+		cf.entries = append(cf.entries, entry)
 		if _, ok := cfg[key]; ok {
 			cfg[key] = append(cfg[key], value)
 
@@ -75,6 +141,7 @@ func (cf *parser) parse() (map[string][]string, error) {
 }
 
 func (cf *parser) nextChar() byte {
+	cf.prev = cf.offset()
 	if len(cf.bytes) == 0 {
 		cf.eof = true
 		return byte('\n')
@@ -159,7 +226,7 @@ func (cf *parser) getExtendedSectionKey(name string, c byte) (string, error) {
 	return name, nil
 }
 
-func (cf *parser) getValue(name *string) (string, error) {
+func (cf *parser) getValue(name *string, entry *Entry) (string, error) {
 	var c byte
 	var err error
 	var value string
@@ -175,6 +242,10 @@ func (cf *parser) getValue(name *string) (string, error) {
 		}
 		*name += string(lower(c))
 	}
+	entry.KeyEnd = cf.prev
+	entry.End = entry.KeyEnd
+	entry.ValueStart = -1
+	entry.NoValue = true
 
 	for c == ' ' || c == '\t' {
 		c = cf.nextChar()
@@ -184,10 +255,14 @@ func (cf *parser) getValue(name *string) (string, error) {
 		if c != '=' {
 			return "", ErrInvalidKeyChar
 		}
-		value, err = cf.parseValue()
+		entry.ValueStart = cf.offset()
+		entry.ValueEnd = entry.ValueStart
+		entry.NoValue = false
+		value, err = cf.parseValue(&entry.ValueEnd)
 		if err != nil {
 			return "", err
 		}
+		entry.End = entry.ValueEnd
 	}
 	/*
 	 * We already consumed the \n, but we need linenr to point to
@@ -202,7 +277,9 @@ func (cf *parser) getValue(name *string) (string, error) {
 	return value, err
 }
 
-func (cf *parser) parseValue() (string, error) {
+// parseValue reads the value, and stores the offset where the raw value
+// ends (not including trailing spaces and comments) in end.
+func (cf *parser) parseValue(end *int) (string, error) {
 	var quote, comment bool
 	var space int
 
@@ -261,8 +338,10 @@ func (cf *parser) parseValue() (string, error) {
 				return "", ErrInvalidEscapeSequence
 			}
 			value += string(c)
+			*end = cf.offset()
 			continue
 		}
+		*end = cf.offset()
 		if c == '"' {
 			quote = !quote
 			continue
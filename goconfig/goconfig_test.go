@@ -83,3 +83,50 @@ func BenchmarkParse(b *testing.B) {
 		Parse(bytes)
 	}
 }
+
+func TestParseEntries(t *testing.T) {
+	data := "# comment\n[user] name = Danyel ; inline\n\tbare\n[remote \"origin\"]\n\turl=\"a b\"\n"
+	entries, lineno, err := ParseEntries([]byte(data))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 6, int(lineno))
+	assert.Equal(t, 5, len(entries))
+
+	assert.True(t, entries[0].IsSection())
+	assert.Equal(t, "user", entries[0].Section)
+	assert.Equal(t, "[user]", data[entries[0].Start:entries[0].End])
+
+	assert.Equal(t, "user.name", entries[1].Key)
+	assert.Equal(t, "Danyel", entries[1].Value)
+	assert.Equal(t, uint(2), entries[1].Line)
+	assert.Equal(t, "name = Danyel", data[entries[1].Start:entries[1].End])
+	assert.Equal(t, " Danyel", data[entries[1].ValueStart:entries[1].ValueEnd])
+
+	assert.Equal(t, "user.bare", entries[2].Key)
+	assert.Equal(t, -1, entries[2].ValueStart)
+	assert.Equal(t, "bare", data[entries[2].Start:entries[2].End])
+
+	assert.Equal(t, "remote.origin", entries[3].Section)
+	assert.Equal(t, `[remote "origin"]`, data[entries[3].Start:entries[3].End])
+
+	assert.Equal(t, "remote.origin", entries[4].Section)
+	assert.Equal(t, "a b", entries[4].Value)
+	assert.Equal(t, `"a b"`, data[entries[4].ValueStart:entries[4].ValueEnd])
+}
+
+func TestNoValue(t *testing.T) {
+	entries, _, err := ParseEntries([]byte("[core]\n\tbare\n\tempty =\n\tquoted = \"\"\n\tvalue = 1\n[a] b"))
+	assert.Equal(t, nil, err)
+	result := map[string]bool{}
+	for _, e := range entries {
+		if !e.IsSection() {
+			result[e.Key] = e.NoValue
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"core.bare":   true,
+		"core.empty":  false,
+		"core.quoted": false,
+		"core.value":  false,
+		"a.b":         true,
+	}, result)
+}
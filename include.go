@@ -0,0 +1,179 @@
+package gitconfig
+
+import (
+	"bufio"
+	"path/filepath"
+	"strings"
+)
+
+// includeContext holds information of a repository, which is used to
+// evaluate conditions of "includeIf" sections.
+type includeContext struct {
+	fsys       FS
+	gitDir     string
+	branch     string
+	remoteURLs []string
+}
+
+// newIncludeContext returns includeContext for the given gitdir in fsys
+func newIncludeContext(fsys FS, gitDir string) *includeContext {
+	var err error
+
+	if gitDir == "" {
+		return nil
+	}
+	if gitDir, err = absPath(gitDir); err != nil {
+		return nil
+	}
+	ctx := &includeContext{
+		fsys:   fsys,
+		gitDir: gitDir,
+		branch: currentBranch(fsys, gitDir),
+	}
+
+	// Collect remote URLs for "hasconfig:remote.*.url:", while these
+	// conditions are evaluated as false.
+	cfg, _ := defaultConfig(fsys, ctx, nil)
+	if commonDir, err := getGitCommonDir(fsys, gitDir); err == nil {
+		if repoConfig, err := loadFile(fsys, filepath.Join(commonDir, "config"), ctx, nil); err == nil {
+			cfg.Merge(repoConfig, ScopeSelf)
+		}
+	}
+	cfg.mergeCommandConfig()
+	urls := []string{}
+	for _, s := range cfg.Sections() {
+		if strings.HasPrefix(s, "remote.") {
+			urls = append(urls, cfg.GetAll(s+".url")...)
+		}
+	}
+	ctx.remoteURLs = urls
+	return ctx
+}
+
+// key returns a string to identify the context in cache
+func (v *includeContext) key() string {
+	if v == nil {
+		return ""
+	}
+	return v.gitDir + "\x00" + v.branch + "\x00" + strings.Join(v.remoteURLs, "\x00")
+}
+
+// currentBranch returns short name of current branch, or empty string
+// if HEAD is detached.
+func currentBranch(fsys FS, gitDir string) string {
+	f, err := openFile(fsys, filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return ""
+	}
+	line := strings.TrimSpace(s.Text())
+	if !strings.HasPrefix(line, "ref:") {
+		return ""
+	}
+	ref := strings.TrimSpace(strings.TrimPrefix(line, "ref:"))
+	if !strings.HasPrefix(ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
+
+// addTrailingStarStar appends "**" to pattern which ends with "/"
+func addTrailingStarStar(pattern string) string {
+	if strings.HasSuffix(pattern, "/") {
+		return pattern + "**"
+	}
+	return pattern
+}
+
+// match evaluates condition of "includeIf" for config file filename
+func (v *includeContext) match(cond, filename string) bool {
+	if v == nil {
+		return false
+	}
+	if strings.HasPrefix(cond, "gitdir:") {
+		return v.matchGitDir(strings.TrimPrefix(cond, "gitdir:"), filename, 0)
+	} else if strings.HasPrefix(cond, "gitdir/i:") {
+		return v.matchGitDir(strings.TrimPrefix(cond, "gitdir/i:"), filename, wmCaseFold)
+	} else if strings.HasPrefix(cond, "onbranch:") {
+		if v.branch == "" {
+			return false
+		}
+		pattern := addTrailingStarStar(strings.TrimPrefix(cond, "onbranch:"))
+		return wildmatch(pattern, v.branch, wmPathname)
+	} else if strings.HasPrefix(cond, "hasconfig:remote.*.url:") {
+		pattern := strings.TrimPrefix(cond, "hasconfig:remote.*.url:")
+		for _, url := range v.remoteURLs {
+			if wildmatch(pattern, url, wmPathname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGitDir matches gitdir of the repository with pattern, which
+// may start with "~/" or "./", the same as git does.
+func (v *includeContext) matchGitDir(pattern, filename string, flags int) bool {
+	var (
+		prefix int
+		err    error
+	)
+
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		trailingSlash := strings.HasSuffix(pattern, "/")
+		if pattern, err = expendHome(pattern); err != nil {
+			return false
+		}
+		pattern = filepath.ToSlash(pattern)
+		if trailingSlash && !strings.HasSuffix(pattern, "/") {
+			pattern += "/"
+		}
+	}
+	if strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, ".\\") {
+		if filename == "" {
+			return false
+		}
+		dir, err := absPath(filename)
+		if err != nil {
+			return false
+		}
+		if real, err := evalSymlinks(v.fsys, dir); err == nil {
+			dir = real
+		}
+		dir = filepath.ToSlash(filepath.Dir(dir))
+		pattern = dir + pattern[1:]
+		prefix = len(dir) + 1
+	} else if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = addTrailingStarStar(pattern)
+
+	texts := []string{}
+	if real, err := evalSymlinks(v.fsys, v.gitDir); err == nil {
+		texts = append(texts, filepath.ToSlash(real))
+	}
+	texts = append(texts, filepath.ToSlash(v.gitDir))
+	for _, text := range texts {
+		if prefix > 0 {
+			if len(text) < prefix {
+				continue
+			}
+			if flags&wmCaseFold != 0 {
+				if !strings.EqualFold(pattern[:prefix], text[:prefix]) {
+					continue
+				}
+			} else if pattern[:prefix] != text[:prefix] {
+				continue
+			}
+		}
+		if wildmatch(pattern[prefix:], text[prefix:], wmPathname|flags) {
+			return true
+		}
+	}
+	return false
+}
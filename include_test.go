@@ -0,0 +1,124 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalInclude(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome(tmpdir)
+
+	sysConfigFile := filepath.Join(tmpdir, "system-config")
+	os.Setenv(gitSystemConfigEnv, sysConfigFile)
+	defer os.Unsetenv(gitSystemConfigEnv)
+
+	for name, content := range map[string]string{
+		".gitconfig": `[user]
+	email = default@example.com
+[includeIf "gitdir:~/work/"]
+	path = .gitconfig-work
+[includeIf "gitdir/i:~/CASE/"]
+	path = .gitconfig-case
+[includeIf "onbranch:feature/"]
+	path = .gitconfig-feature
+[includeIf "hasconfig:remote.*.url:https://example.com/**"]
+	path = .gitconfig-example
+[includeIf "gitdir:~/missing/"]
+	path = .gitconfig-missing
+`,
+		".gitconfig-work":    "[user]\n\temail = work@example.com\n",
+		".gitconfig-case":    "[user]\n\temail = case@example.com\n",
+		".gitconfig-feature": "[test]\n\tfeature = yes\n",
+		".gitconfig-example": "[test]\n\texample = yes\n",
+	} {
+		err = ioutil.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644)
+		assert.Nil(err)
+	}
+
+	for _, dir := range []string{"work/repo", "case/repo", "other/repo", "missing/repo"} {
+		assert.Nil(exec.Command("git", "init", "-q", filepath.Join(tmpdir, dir), "--").Run())
+	}
+	assert.Nil(exec.Command("git", "-C", filepath.Join(tmpdir, "other/repo"),
+		"checkout", "-q", "-b", "feature/topic").Run())
+	assert.Nil(exec.Command("git", "-C", filepath.Join(tmpdir, "other/repo"),
+		"remote", "add", "origin", "https://example.com/repo.git").Run())
+
+	for _, tc := range []struct {
+		Dir     string
+		Email   string
+		Feature string
+		Example string
+	}{
+		{"work/repo", "work@example.com", "", ""},
+		{"case/repo", "case@example.com", "", ""},
+		{"other/repo", "default@example.com", "yes", "yes"},
+		{"missing/repo", "default@example.com", "", ""},
+		{"", "default@example.com", "", ""},
+	} {
+		cfg, err := LoadDirWithDefault(filepath.Join(tmpdir, tc.Dir))
+		assert.Nil(err)
+		assert.Equal(tc.Email, cfg.Get("user.email"), "user.email in '%s'", tc.Dir)
+		assert.Equal(tc.Feature, cfg.Get("test.feature"), "test.feature in '%s'", tc.Dir)
+		assert.Equal(tc.Example, cfg.Get("test.example"), "test.example in '%s'", tc.Dir)
+	}
+
+	repo, err := FindRepository(filepath.Join(tmpdir, "work/repo"))
+	assert.Nil(err)
+	assert.Equal("work@example.com", repo.Config().Get("user.email"))
+
+	// Without repository, conditions are not matched
+	assert.Equal("default@example.com", DefaultConfig().Get("user.email"))
+}
+
+func TestIncludeRelativeGitDir(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	cfgFile := filepath.Join(tmpdir, "shared", "config")
+	assert.Nil(os.MkdirAll(filepath.Dir(cfgFile), 0755))
+	assert.Nil(ioutil.WriteFile(cfgFile, []byte(`[includeIf "gitdir:./repo/"]
+	path = inc`), 0644))
+	assert.Nil(ioutil.WriteFile(filepath.Join(tmpdir, "shared", "inc"),
+		[]byte("[a]\n\tb = c\n"), 0644))
+
+	ctx := &includeContext{fsys: OSFS{}, gitDir: filepath.Join(tmpdir, "shared", "repo", ".git")}
+	cfg, err := loadFile(OSFS{}, cfgFile, ctx, nil)
+	assert.Nil(err)
+	assert.Equal("c", cfg.Get("a.b"))
+
+	ctx = &includeContext{fsys: OSFS{}, gitDir: filepath.Join(tmpdir, "repo", ".git")}
+	cfg, err = loadFile(OSFS{}, cfgFile, ctx, nil)
+	assert.Nil(err)
+	assert.Equal("", cfg.Get("a.b"))
+}
@@ -1,51 +1,112 @@
 package gitconfig
 
 import (
-	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
-// LoadFile loads specific git config file.
+// LoadFile loads specific git config file. The returned config is a
+// copy of the cached one, and is free to modify.
 func LoadFile(name string) (GitConfig, error) {
-	if cfg, ok := CacheGet(name); ok {
+	return LoadFileFS(GetFS(), name)
+}
+
+// LoadFileFS loads specific git config file and included files from
+// fsys, like LoadFile.
+func LoadFileFS(fsys FS, name string) (GitConfig, error) {
+	cfg, err := loadFile(fsys, name, nil, nil)
+	return cfg.Clone(), err
+}
+
+// loadFile loads git config file from fsys, and evaluates conditional
+// includes using ctx. The returned config may be shared in cache, so it
+// must not be modified. Files consulted (including included files) are
+// recorded in files.
+func loadFile(fsys FS, name string, ctx *includeContext, files *fileStats) (GitConfig, error) {
+	key := name
+	if ctx != nil {
+		key = name + "\x00" + ctx.key()
+	}
+	if cfg, stats, ok := cacheGet(fsys, key); ok {
+		files.merge(stats)
 		return cfg, nil
 	}
 
-	// cache will be updated using this time
-	fi, err := os.Stat(name)
-	if err != nil {
+	// Stat before reading, so cache will be refreshed if file is
+	// changed while loading.
+	stats := &fileStats{}
+	stats.add(fsys, name)
+	defer func() {
+		files.merge(stats.files)
+	}()
+	if !stats.files[0].exists {
 		return nil, ErrNotExist
 	}
 
-	buf, err := ioutil.ReadFile(name)
+	buf, err := readFile(fsys, name)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg, _, err := Parse(buf, name)
+	cfg, _, err := parse(fsys, buf, Origin{Type: OriginFile, Name: name}, ctx, 0, stats)
 	if err != nil {
 		return cfg, err
 	}
 
 	// update cache
-	CacheSet(name, cfg, fi.Size(), fi.ModTime())
+	cacheSet(fsys, key, cfg, stats.files)
 	return cfg, nil
 }
 
 // LoadFileWithDefault loads specific git config file and fallback
 // to default config (user level config or system level).
 func LoadFileWithDefault(name string) (GitConfig, error) {
-	cfg := DefaultConfig()
+	var ctx *includeContext
 
-	repoConfig, err := LoadFile(name)
+	// Config file of a repository, conditional includes are evaluated
+	// in the context of this repository.
+	fsys := GetFS()
+	if dir, err := absPath(name); err == nil && isGitDir(fsys, filepath.Dir(dir)) {
+		ctx = newIncludeContext(fsys, filepath.Dir(dir))
+	}
+	cfg, _ := loadFileWithDefault(fsys, name, ctx, nil)
+	return cfg, nil
+}
+
+// loadFileWithDefault loads git config file and default config from fsys
+// with ctx, and records files consulted in files. Files which fail to load are
+// skipped, and the first error other than ErrNotExist is returned with
+// config of the other files.
+func loadFileWithDefault(fsys FS, name string, ctx *includeContext, files *fileStats) (GitConfig, error) {
+	cfg, loadErr := defaultConfig(fsys, ctx, files)
+
+	// Conditional includes of "onbranch:" depend on HEAD
+	if ctx != nil {
+		files.add(fsys, filepath.Join(ctx.gitDir, "HEAD"))
+	}
+
+	repoConfig, err := loadFile(fsys, name, ctx, files)
 	if err == nil {
 		cfg.Merge(repoConfig, ScopeSelf)
+
+		// Per-worktree config has higher priority than repository config
+		if ctx != nil && repoConfig.GetBool("extensions.worktreeConfig", false) {
+			worktreeConfig, err := loadFile(fsys, filepath.Join(ctx.gitDir, worktreeConfigName), ctx, files)
+			if err == nil {
+				cfg.Merge(worktreeConfig, ScopeWorktree)
+			} else if err != ErrNotExist && loadErr == nil {
+				loadErr = err
+			}
+		}
+	} else if err != ErrNotExist && loadErr == nil {
+		loadErr = err
 	}
-	return cfg, nil
+	return cfg.mergeCommandConfig(), loadErr
 }
 
-// LoadDir only loads git config file found in gitdir.
-func LoadDir(dir string) (GitConfig, error) {
+// findConfigWithContext finds config file in gitdir in fsys, and returns
+// context for conditional includes.
+func findConfigWithContext(fsys FS, dir string) (string, *includeContext, error) {
 	var (
 		err error
 	)
@@ -53,63 +114,146 @@ func LoadDir(dir string) (GitConfig, error) {
 	if dir == "" {
 		dir, err = os.Getwd()
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 	}
-	configFile, err := FindGitConfig(dir)
+	gitDir, err := findGitDir(fsys, dir)
 	if err != nil {
-		return nil, ErrNotExist
+		return "", nil, ErrNotExist
+	}
+	commonDir, err := getGitCommonDir(fsys, gitDir)
+	if err != nil {
+		return "", nil, ErrNotExist
 	}
+	return filepath.Join(commonDir, "config"), newIncludeContext(fsys, gitDir), nil
+}
 
-	return LoadFile(configFile)
+// LoadDir only loads git config file found in gitdir.
+func LoadDir(dir string) (GitConfig, error) {
+	return LoadDirFS(GetFS(), dir)
+}
+
+// LoadDirFS only loads git config file found in gitdir in fsys, like
+// LoadDir.
+func LoadDirFS(fsys FS, dir string) (GitConfig, error) {
+	configFile, ctx, err := findConfigWithContext(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadFile(fsys, configFile, ctx, nil)
+	return cfg.Clone(), err
 }
 
 // LoadDirWithDefault loads git config file found in gitdir, and
 // fallback to default (global and system level git config).
 func LoadDirWithDefault(dir string) (GitConfig, error) {
-	cfg := DefaultConfig()
+	cfg, _ := loadDirWithDefault(GetFS(), dir, nil)
+	return cfg, nil
+}
 
-	repoConfig, err := LoadDir(dir)
-	if err == nil {
-		cfg.Merge(repoConfig, ScopeSelf)
+// loadDirWithDefault loads config of repository in dir with default
+// config from fsys, and records files consulted in files. Error of files
+// which fail to load is returned, see loadFileWithDefault.
+func loadDirWithDefault(fsys FS, dir string, files *fileStats) (GitConfig, error) {
+	configFile, ctx, err := findConfigWithContext(fsys, dir)
+	if err != nil {
+		cfg, err := defaultConfig(fsys, nil, files)
+		return cfg.mergeCommandConfig(), err
 	}
-	return cfg, nil
+	return loadFileWithDefault(fsys, configFile, ctx, files)
 }
 
 // SystemConfig returns system git config, reload if necessary
 func SystemConfig() (GitConfig, error) {
+	cfg, err := systemConfig(GetFS(), nil, nil)
+	return cfg.Clone(), err
+}
+
+func systemConfig(fsys FS, ctx *includeContext, files *fileStats) (GitConfig, error) {
+	enabled, err := SystemConfigEnabledE()
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
 	file := SystemConfigFile()
 	if file == "" {
 		return nil, nil
 	}
 
-	if _, err := os.Stat(file); err != nil {
+	if _, err := statFile(fsys, file); err != nil {
+		files.add(fsys, file)
 		return nil, nil
 	}
-	return LoadFile(file)
+	return loadFile(fsys, file, ctx, files)
 }
 
-// GlobalConfig returns global user config, reload if necessary
+// GlobalConfig returns global user config, reload if necessary.
+// Both XDG config file and "~/.gitconfig" are loaded as git does.
 func GlobalConfig() (GitConfig, error) {
-	file, err := GlobalConfigFile()
+	return globalConfig(GetFS(), nil, nil)
+}
+
+func globalConfig(fsys FS, ctx *includeContext, files *fileStats) (GitConfig, error) {
+	var cfg GitConfig
+
+	configFiles, err := GlobalConfigFiles()
 	if err != nil {
 		return nil, nil
 	}
 
-	if _, err := os.Stat(file); err != nil {
-		return nil, nil
+	for _, file := range configFiles {
+		if _, err := statFile(fsys, file); err != nil {
+			files.add(fsys, file)
+			continue
+		}
+		fileCfg, err := loadFile(fsys, file, ctx, files)
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil {
+			cfg = NewGitConfig()
+		}
+		cfg.Merge(fileCfg, ScopeSelf)
 	}
-	return LoadFile(file)
+	return cfg, nil
 }
 
-// DefaultConfig returns global and system wide config
+// DefaultConfig returns global and system wide config, and config from
+// command line (see CommandConfig) which has the highest priority.
 func DefaultConfig() GitConfig {
+	cfg, _ := defaultConfig(GetFS(), nil, nil)
+	return cfg.mergeCommandConfig()
+}
+
+// mergeCommandConfig merges config from command line, bad entries in
+// environments are ignored.
+func (v GitConfig) mergeCommandConfig() GitConfig {
+	cmdCfg, _ := CommandConfig()
+	return v.Merge(cmdCfg, ScopeCommand)
+}
+
+// defaultConfig returns global and system wide config in fsys, and
+// conditional includes are evaluated using ctx. Files consulted are
+// recorded in files.
+// Config which fails to load is skipped, and the first error is returned.
+func defaultConfig(fsys FS, ctx *includeContext, files *fileStats) (GitConfig, error) {
+	var loadErr error
+
 	cfg := NewGitConfig()
-	if sysCfg, err := SystemConfig(); err == nil && sysCfg != nil {
+	sysCfg, err := systemConfig(fsys, ctx, files)
+	if err == nil && sysCfg != nil {
 		cfg.Merge(sysCfg, ScopeSystem)
+	} else if err != nil {
+		loadErr = err
 	}
-	if globalCfg, err := GlobalConfig(); err == nil && globalCfg != nil {
+	globalCfg, err := globalConfig(fsys, ctx, files)
+	if err == nil && globalCfg != nil {
 		cfg.Merge(globalCfg, ScopeGlobal)
+	} else if err != nil && loadErr == nil {
+		loadErr = err
 	}
-	return cfg
+	return cfg, loadErr
 }
@@ -562,10 +562,180 @@ func TestSaveConfig(t *testing.T) {
 	// Load new file
 	newCfg, err := LoadFile(newCfgFile)
 	assert.Nil(err)
-	assert.Equal(cfg, newCfg)
+	assert.Equal(cfg.Keys(), newCfg.Keys())
+	assert.Equal(cfg.String(), newCfg.String())
 	assert.Equal("value-1", newCfg.Get("ab.CD.ef"))
 	assert.Equal("value-1", newCfg.Get("Ab.CD.Ef"))
 	assert.Equal("", newCfg.Get("ab.cd.ef"))
 	assert.Equal("value has space ", cfg.Get("ab.cd"))
 	assert.Equal("value has space ", cfg.Get("ab.cD"))
 }
+
+func TestIncludeInPlace(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	for name, content := range map[string]string{
+		"config": `[a]
+	x = 1
+[include]
+	path = inc1
+[a]
+	x = 3
+[include]
+	path = inc2
+	path = inc1
+[a]
+	y = 1`,
+		"inc1": "[a]\n\tx = 2\n",
+		"inc2": "[a]\n\tx = 4\n\ty = 4\n[include]\n\tpath = inc3\n",
+		"inc3": "[a]\n\tx = 5\n",
+	} {
+		err = ioutil.WriteFile(filepath.Join(tmpdir, name), []byte(content), 0644)
+		assert.Nil(err)
+	}
+
+	cfgFile := filepath.Join(tmpdir, "config")
+	cfg, err := LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal([]string{"1", "2", "3", "4", "5", "2"}, cfg.GetAll("a.x"))
+	assert.Equal("2", cfg.Get("a.x"))
+	assert.Equal([]string{"4", "1"}, cfg.GetAll("a.y"))
+	assert.Equal([]string{"inc1", "inc2", "inc3", "inc1"}, cfg.GetAll("include.path"))
+
+	// Check with git
+	output, err := exec.Command("git", "config", "-f", cfgFile, "--includes", "--get-all", "a.x").Output()
+	assert.Nil(err)
+	assert.Equal("1\n2\n3\n4\n5\n2\n", string(output))
+}
+
+func TestDefaultConfigEnv(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome(tmpdir)
+
+	sysCfgFile := filepath.Join(tmpdir, "system-config")
+	os.Setenv(gitSystemConfigEnv, sysCfgFile)
+	defer os.Unsetenv(gitSystemConfigEnv)
+	userCfgFile := filepath.Join(tmpdir, ".gitconfig")
+	otherCfgFile := filepath.Join(tmpdir, "other-config")
+
+	assert.Nil(exec.Command("git", "config", "-f", sysCfgFile, "test.sys", "sys").Run())
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "test.user", "user").Run())
+	assert.Nil(exec.Command("git", "config", "-f", otherCfgFile, "test.other", "other").Run())
+
+	cfg := DefaultConfig()
+	assert.Equal("sys", cfg.Get("test.sys"))
+	assert.Equal("user", cfg.Get("test.user"))
+
+	os.Setenv(gitConfigNoSystemEnv, "1")
+	defer os.Unsetenv(gitConfigNoSystemEnv)
+	os.Setenv(gitConfigGlobalEnv, os.DevNull)
+	defer os.Unsetenv(gitConfigGlobalEnv)
+	cfg = DefaultConfig()
+	assert.Equal(GitConfig{}, cfg)
+
+	os.Setenv(gitConfigNoSystemEnv, "0")
+	os.Setenv(gitConfigSystemEnv, otherCfgFile)
+	defer os.Unsetenv(gitConfigSystemEnv)
+	os.Setenv(gitConfigGlobalEnv, otherCfgFile)
+	cfg = DefaultConfig()
+	assert.Equal([]string{"other", "other"}, cfg.GetAll("test.other"))
+	assert.Equal("", cfg.Get("test.sys"))
+	assert.Equal("", cfg.Get("test.user"))
+}
+
+func TestGlobalConfigFiles(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome(tmpdir)
+
+	xdgHome, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	if ok {
+		defer os.Setenv("XDG_CONFIG_HOME", xdgHome)
+	} else {
+		defer os.Unsetenv("XDG_CONFIG_HOME")
+	}
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpdir, "xdg"))
+
+	xdgCfgFile := filepath.Join(tmpdir, "xdg", "git", "config")
+	userCfgFile := filepath.Join(tmpdir, ".gitconfig")
+	files, err := GlobalConfigFiles()
+	assert.Nil(err)
+	assert.Equal([]string{xdgCfgFile, userCfgFile}, files)
+
+	// Write to ~/.gitconfig if none exists
+	file, err := GlobalConfigFile()
+	assert.Nil(err)
+	assert.Equal(userCfgFile, file)
+
+	// Write to xdg config file if only it exists
+	assert.Nil(os.MkdirAll(filepath.Dir(xdgCfgFile), 0755))
+	assert.Nil(exec.Command("git", "config", "-f", xdgCfgFile, "test.key1", "xdg 1").Run())
+	assert.Nil(exec.Command("git", "config", "-f", xdgCfgFile, "test.key2", "xdg 2").Run())
+	file, err = GlobalConfigFile()
+	assert.Nil(err)
+	assert.Equal(xdgCfgFile, file)
+
+	// Write to ~/.gitconfig if it exists
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "test.key2", "user 2").Run())
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "test.key3", "user 3").Run())
+	file, err = GlobalConfigFile()
+	assert.Nil(err)
+	assert.Equal(userCfgFile, file)
+
+	// Both files are loaded, and ~/.gitconfig has higher priority
+	cfg, err := GlobalConfig()
+	assert.Nil(err)
+	assert.Equal("xdg 1", cfg.Get("test.key1"))
+	assert.Equal([]string{"xdg 2", "user 2"}, cfg.GetAll("test.key2"))
+	assert.Equal("user 3", cfg.Get("test.key3"))
+
+	cfg = DefaultConfig()
+	assert.Equal([]string{"xdg 2", "user 2"}, cfg.GetAll("test.key2"))
+	value, ok := cfg.GetWithOrigin("test.key1")
+	assert.True(ok)
+	assert.Equal("global", value.Scope)
+	assert.Equal(xdgCfgFile, value.Origin.Name)
+}
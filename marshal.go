@@ -0,0 +1,232 @@
+package gitconfig
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Marshal returns a new GitConfig holding the struct v (or pointer to
+// struct), see MarshalTo.
+func Marshal(v interface{}) (GitConfig, error) {
+	cfg := NewGitConfig()
+	if err := MarshalTo(cfg, v); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MarshalTo updates cfg with fields of the struct v (or pointer to
+// struct), which are tagged the same as Unmarshal. Scalar fields are
+// saved with Set, and slice fields replace all values of the key like
+// UnsetAll and Add. Keys are only changed if their values are different
+// from the fields as parsed by Unmarshal, so "yes" is kept for true,
+// and keys with option "default=value" are not added for the default
+// value. So cfg.Save() only rewrites lines really changed.
+//
+// Nil pointers, and zero values of fields with option "omitempty", are
+// skipped and keys of them are left as is. Subsections and keys not in
+// maps are also left as is. Other fields are always written: a field
+// with zero value adds its key if the key is not in cfg, such as
+// "core.bare = false" for a bool field. Use "omitempty" or a pointer
+// field to leave such keys out.
+func MarshalTo(cfg GitConfig, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot marshal %T into config, need a struct", v)
+	}
+	e := &encoder{cfg: cfg}
+	return e.encodeStruct(rv, "")
+}
+
+// encoder saves struct fields into config
+type encoder struct {
+	cfg GitConfig
+}
+
+func (e *encoder) encodeStruct(rv reflect.Value, prefix string) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("gitconfig")
+		if tag == "-" {
+			continue
+		}
+		if !ok {
+			if f.Anonymous && isStructType(f.Type) && f.Type.Kind() != reflect.Ptr {
+				if err := e.encodeStruct(rv.Field(i), prefix); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		opts := parseFieldTag(tag)
+		if err := e.encodeField(rv.Field(i), joinKey(prefix, opts.name), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeField(fv reflect.Value, key string, opts fieldTag) error {
+	t := fv.Type()
+	if opts.omitEmpty && isEmptyValue(fv) {
+		return nil
+	}
+	switch {
+	case isValuesType(t):
+		if section, k := toSectionKey(key); section == "" || k == "" {
+			return fmt.Errorf("invalid config key: %s", key)
+		}
+		if isScalarType(t) {
+			return e.encodeValue(fv, key, opts)
+		}
+		return e.encodeValues(fv, key)
+	case isStructType(t):
+		if t.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				return nil
+			}
+			fv = fv.Elem()
+		}
+		return e.encodeStruct(fv, key)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		(isStructType(t.Elem()) || isValuesType(t.Elem())):
+		section := sectionName(key)
+		keys := fv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, name := range keys {
+			if err := e.encodeField(fv.MapIndex(name), section+"."+name.String(), fieldTag{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot marshal '%s' from unsupported type %s", key, t)
+}
+
+// encodeValue sets key to value of a scalar field if changed
+func (e *encoder) encodeValue(fv reflect.Value, key string, opts fieldTag) error {
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return nil
+	}
+	value, err := formatFieldValue(fv)
+	if err != nil {
+		return err
+	}
+	values := e.cfg.getRaw(key)
+	if len(values) == 0 {
+		if opts.hasDefault && sameFieldValue(fv, gitConfigValue{value: opts.defaultValue}) {
+			return nil
+		}
+		e.cfg.Set(key, value)
+		return nil
+	}
+	if !sameFieldValue(fv, values[len(values)-1]) {
+		e.cfg.Set(key, value)
+	}
+	return nil
+}
+
+// encodeValues replaces all values of key with a slice field if changed
+func (e *encoder) encodeValues(fv reflect.Value, key string) error {
+	values := e.cfg.getRaw(key)
+	same := len(values) == fv.Len()
+	for i := 0; same && i < fv.Len(); i++ {
+		same = sameFieldValue(fv.Index(i), values[i])
+	}
+	if same {
+		return nil
+	}
+
+	newValues := make([]string, fv.Len())
+	for i := range newValues {
+		value, err := formatFieldValue(fv.Index(i))
+		if err != nil {
+			return err
+		}
+		newValues[i] = value
+	}
+	e.cfg.UnsetAll(key)
+	for _, value := range newValues {
+		e.cfg.Add(key, value)
+	}
+	return nil
+}
+
+// sameFieldValue checks whether value is parsed to the same as fv
+func sameFieldValue(fv reflect.Value, value gitConfigValue) bool {
+	parsed := reflect.New(fv.Type()).Elem()
+	if err := setFieldValue(parsed, value); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(parsed.Interface(), fv.Interface())
+}
+
+// formatFieldValue converts a scalar field to config value, which can be
+// parsed back by Unmarshal.
+func formatFieldValue(fv reflect.Value) (string, error) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", fmt.Errorf("cannot marshal nil %s", fv.Type())
+		}
+		fv = fv.Elem()
+	}
+	if fv.Type().Implements(textMarshalerType) {
+		text, err := fv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textMarshalerType) {
+		text, err := fv.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if fv.Type() == durationType {
+		return time.Duration(fv.Int()).String(), nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'g', -1, fv.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("cannot marshal unsupported type %s", fv.Type())
+}
+
+// isEmptyValue checks zero value for option "omitempty", like encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
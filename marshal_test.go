@@ -0,0 +1,183 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Zero values of fields with "omitempty" are not written
+type testMarshalRemoteConfig struct {
+	URL    []string `gitconfig:"url"`
+	Fetch  []string `gitconfig:"fetch"`
+	Prune  *bool    `gitconfig:"prune"`
+	TagOpt string   `gitconfig:"tagOpt,omitempty"`
+}
+
+type testMarshalConfig struct {
+	testUserConfig
+
+	Core       testCoreConfig                     `gitconfig:"core"`
+	Remotes    map[string]testMarshalRemoteConfig `gitconfig:"remote"`
+	Aliases    map[string]string                  `gitconfig:"alias"`
+	GCAuto     int                                `gitconfig:"gc.auto,default=6700"`
+	WindowSize uint64                             `gitconfig:"pack.windowMemory"`
+	Timeout    time.Duration                      `gitconfig:"http.timeout,default=1m"`
+	Proxy      net.IP                             `gitconfig:"http.proxyAddr,omitempty"`
+	Ratio      float64                            `gitconfig:"test.ratio,omitempty"`
+	Ignored    string
+	Skipped    string `gitconfig:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		compression = 9
+		prune       = true
+		result      testMarshalConfig
+	)
+
+	v := testMarshalConfig{
+		testUserConfig: testUserConfig{Name: "Tester"},
+		Core: testCoreConfig{
+			Bare:        true,
+			AutoCRLF:    "false",
+			Compression: &compression,
+		},
+		Remotes: map[string]testMarshalRemoteConfig{
+			"origin": {
+				URL:   []string{"https://example.com/a.git", "https://example.com/b.git"},
+				Prune: &prune,
+			},
+		},
+		Aliases:    map[string]string{"co": "checkout"},
+		GCAuto:     6700,
+		WindowSize: 1 << 30,
+		Timeout:    90 * time.Second,
+		Proxy:      net.ParseIP("10.0.0.1"),
+		Ratio:      0.5,
+		Ignored:    "ignored",
+	}
+	cfg, err := Marshal(&v)
+	assert.Nil(err)
+	assert.Equal([]string{
+		"alias.co",
+		"core.bare",
+		"core.compression",
+		"http.proxyaddr",
+		"http.timeout",
+		"pack.windowmemory",
+		"remote.origin.prune",
+		"remote.origin.url",
+		"test.ratio",
+		"user.email",
+		"user.name",
+	}, cfg.Keys())
+	assert.Equal("1m30s", cfg.Get("http.timeout"))
+	assert.Equal("1073741824", cfg.Get("pack.windowMemory"))
+	assert.Equal([]string{"https://example.com/a.git", "https://example.com/b.git"},
+		cfg.GetAll("remote.origin.url"))
+	assert.Nil(cfg.GetAll("remote.origin.fetch"))
+
+	v.Ignored = ""
+	assert.Nil(Unmarshal(cfg, &result))
+	assert.Equal(v, result)
+
+	_, err = Marshal("bad")
+	assert.NotNil(err)
+}
+
+func TestMarshalMinimalDiff(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		v      testMarshalConfig
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	cfgFile := filepath.Join(tmpdir, "config")
+	err = ioutil.WriteFile(cfgFile, []byte(`# provisioned
+[user]
+	name = Tester
+[core]
+	bare = yes ; keep spelling
+	compression = 9
+[remote "origin"]
+	url = https://example.com/a.git
+	prune
+[remote "upstream"]
+	url = https://example.com/up.git
+[pack]
+	windowMemory = 1g
+`), 0644)
+	assert.Nil(err)
+
+	cfg, err := LoadFile(cfgFile)
+	assert.Nil(err)
+	assert.Nil(Unmarshal(cfg, &v))
+
+	v.Email = "tester@example.com"
+	v.Remotes = map[string]testMarshalRemoteConfig{
+		"origin": {
+			URL:   []string{"https://example.com/a.git", "https://example.com/b.git"},
+			Prune: v.Remotes["origin"].Prune,
+		},
+	}
+	v.Timeout = 2 * time.Minute
+	assert.Nil(MarshalTo(cfg, v))
+	assert.Nil(cfg.Save(cfgFile))
+
+	data, err := ioutil.ReadFile(cfgFile)
+	assert.Nil(err)
+	assert.Equal(`# provisioned
+[user]
+	name = Tester
+	email = tester@example.com
+[core]
+	bare = yes ; keep spelling
+	compression = 9
+[remote "origin"]
+	url = https://example.com/a.git
+	url = https://example.com/b.git
+	prune
+[remote "upstream"]
+	url = https://example.com/up.git
+[pack]
+	windowMemory = 1g
+[http]
+	timeout = 2m0s
+`, string(data))
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	var v struct {
+		Name    string   `gitconfig:"user.name,omitempty"`
+		Email   string   `gitconfig:"user.email"`
+		Signing bool     `gitconfig:"commit.gpgSign,omitempty"`
+		Fetch   []string `gitconfig:"remote.origin.fetch,omitempty"`
+	}
+
+	cfg := NewGitConfig()
+	cfg.Set("user.name", "Tester")
+	cfg.Set("commit.gpgSign", "true")
+	cfg.Add("remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+	assert.Nil(MarshalTo(cfg, v))
+	assert.Equal("Tester", cfg.Get("user.name"))
+	assert.True(cfg.HasKey("user.email"))
+	assert.Equal("", cfg.Get("user.email"))
+	assert.Equal("true", cfg.Get("commit.gpgsign"))
+	assert.Equal([]string{"+refs/heads/*:refs/remotes/origin/*"}, cfg.GetAll("remote.origin.fetch"))
+}
@@ -0,0 +1,104 @@
+package gitconfig
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// errNotifierClosed indicates watch is called after notifier is closed
+var errNotifierClosed = errors.New("notifier is closed")
+
+// inotify notifies changes of files in directories using inotify(7)
+type inotify struct {
+	fd   int
+	file *os.File
+	wds  map[string]int
+	ch   chan struct{}
+
+	// mutex protects fd from being closed (and reused) while adding
+	// or removing watches.
+	mutex  sync.Mutex
+	closed bool
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	v := &inotify{
+		fd: fd,
+		// Non-blocking fd is pollable, and Read is interrupted by Close
+		file: os.NewFile(uintptr(fd), "inotify"),
+		wds:  make(map[string]int),
+		ch:   make(chan struct{}, 1),
+	}
+	go v.read()
+	return v, nil
+}
+
+// read reads inotify events until closed. Events are not parsed, for
+// watcher checks states of files anyway.
+func (v *inotify) read() {
+	buf := make([]byte, 4096)
+	for {
+		if _, err := v.file.Read(buf); err != nil {
+			return
+		}
+		select {
+		case v.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (v *inotify) watch(dirs []string) error {
+	var firstErr error
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.closed {
+		return errNotifierClosed
+	}
+
+	wanted := make(map[string]bool)
+	for _, dir := range dirs {
+		wanted[dir] = true
+		// Adding watch again is harmless, and recovers watch of
+		// directory which was removed and created again.
+		wd, err := syscall.InotifyAddWatch(v.fd, dir, inotifyMask)
+		if err != nil {
+			delete(v.wds, dir)
+			// Missing directories are checked by polling
+			if err != syscall.ENOENT && err != syscall.ENOTDIR && firstErr == nil {
+				firstErr = &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+			}
+			continue
+		}
+		v.wds[dir] = wd
+	}
+	for dir, wd := range v.wds {
+		if !wanted[dir] {
+			syscall.InotifyRmWatch(v.fd, uint32(wd))
+			delete(v.wds, dir)
+		}
+	}
+	return firstErr
+}
+
+func (v *inotify) events() <-chan struct{} {
+	return v.ch
+}
+
+func (v *inotify) close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.closed = true
+	return v.file.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package gitconfig
+
+// newNotifier returns nil, and config files are checked by polling
+func newNotifier() (notifier, error) {
+	return nil, nil
+}
@@ -0,0 +1,130 @@
+package gitconfig
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// unitFactor returns factor of unit suffix "k", "m" or "g", which is
+// case-insensitive.
+func unitFactor(unit string) (uint64, bool) {
+	switch strings.ToLower(unit) {
+	case "":
+		return 1, true
+	case "k":
+		return 1024, true
+	case "m":
+		return 1024 * 1024, true
+	case "g":
+		return 1024 * 1024 * 1024, true
+	}
+	return 0, false
+}
+
+// parseNumber parses leading number of value like strtoimax() of C with
+// base 0: leading spaces and sign are allowed, "0x" prefix is for hex
+// and "0" prefix is for octal. It returns absolute value, sign and the
+// rest of value which is the unit, or ErrInvalidUnit if no digits.
+func parseNumber(value string) (uint64, bool, string, error) {
+	s := strings.TrimLeft(value, " \t\n\v\f\r")
+	negative := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	base := 10
+	digits := "0123456789"
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') &&
+		strings.IndexByte("0123456789abcdefABCDEF", s[2]) >= 0 {
+		base = 16
+		digits = "0123456789abcdefABCDEF"
+		s = s[2:]
+	} else if len(s) > 1 && s[0] == '0' {
+		base = 8
+		digits = "01234567"
+	}
+	end := 0
+	for end < len(s) && strings.IndexByte(digits, s[end]) >= 0 {
+		end++
+	}
+	if end == 0 {
+		// Unlike C, git rejects value without digits, such as "k"
+		return 0, false, "", ErrInvalidUnit
+	}
+	number, err := strconv.ParseUint(s[:end], base, 64)
+	if err != nil {
+		return 0, false, "", ErrOutOfRange
+	}
+	return number, negative, s[end:], nil
+}
+
+// parseSigned parses value as a signed integer not larger than max in
+// absolute value, the same as git_parse_signed().
+func parseSigned(value string, max int64) (int64, error) {
+	if value == "" {
+		return 0, &NumError{Value: value, Err: ErrInvalidUnit}
+	}
+	number, negative, unit, err := parseNumber(value)
+	if err != nil {
+		return 0, &NumError{Value: value, Err: err}
+	}
+	factor, ok := unitFactor(unit)
+	if !ok {
+		return 0, &NumError{Value: value, Err: ErrInvalidUnit}
+	}
+	if number != 0 && factor > math.MaxUint64/number || factor*number > uint64(max) {
+		return 0, &NumError{Value: value, Err: ErrOutOfRange}
+	}
+	if negative {
+		return -int64(factor * number), nil
+	}
+	return int64(factor * number), nil
+}
+
+// parseUnsigned parses value as an unsigned integer not larger than
+// max, the same as git_parse_unsigned().
+func parseUnsigned(value string, max uint64) (uint64, error) {
+	if value == "" || strings.Contains(value, "-") {
+		return 0, &NumError{Value: value, Err: ErrInvalidUnit}
+	}
+	number, _, unit, err := parseNumber(value)
+	if err != nil {
+		return 0, &NumError{Value: value, Err: err}
+	}
+	factor, ok := unitFactor(unit)
+	if !ok {
+		return 0, &NumError{Value: value, Err: ErrInvalidUnit}
+	}
+	if number != 0 && factor > math.MaxUint64/number || factor*number > max {
+		return 0, &NumError{Value: value, Err: ErrOutOfRange}
+	}
+	return factor * number, nil
+}
+
+// ParseInt parses value as int the same as git, which may have unit
+// suffix "k", "m" or "g" (case-insensitive). Error is a *NumError.
+func ParseInt(value string) (int, error) {
+	result, err := parseSigned(value, math.MaxInt64>>(64-strconv.IntSize))
+	return int(result), err
+}
+
+// ParseInt64 parses value as int64 the same as git, see ParseInt
+func ParseInt64(value string) (int64, error) {
+	return parseSigned(value, math.MaxInt64)
+}
+
+// ParseUint64 parses value as uint64 the same as git, see ParseInt.
+// Negative values are invalid.
+func ParseUint64(value string) (uint64, error) {
+	return parseUnsigned(value, math.MaxUint64)
+}
+
+// numError adds key to error returned by ParseInt and friends
+func numError(key string, err error) error {
+	if e, ok := err.(*NumError); ok {
+		e.Key = key
+	}
+	return err
+}
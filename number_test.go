@@ -0,0 +1,111 @@
+package gitconfig
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInt64(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		Value  string
+		Result int64
+	}{
+		{"0", 0},
+		{"42", 42},
+		{"-42", -42},
+		{"+42", 42},
+		{" 42", 42},
+		{"1k", 1024},
+		{"1K", 1024},
+		{"512m", 512 * 1024 * 1024},
+		{"1g", 1024 * 1024 * 1024},
+		{"-2G", -2 * 1024 * 1024 * 1024},
+		{"524288000k", 524288000 * 1024},
+		{"0x10", 16},
+		{"0X1fk", 31 * 1024},
+		{"010", 8},
+		{"9223372036854775807", math.MaxInt64},
+		{"8589934591g", 8589934591 * 1024 * 1024 * 1024},
+	} {
+		result, err := ParseInt64(tc.Value)
+		if assert.Nil(err, tc.Value) {
+			assert.Equal(tc.Result, result, tc.Value)
+		}
+	}
+
+	for _, tc := range []struct {
+		Value string
+		Err   error
+	}{
+		{"", ErrInvalidUnit},
+		{"abc", ErrInvalidUnit},
+		{"k", ErrInvalidUnit},
+		{"-k", ErrInvalidUnit},
+		{"+g", ErrInvalidUnit},
+		{" ", ErrInvalidUnit},
+		{"1x", ErrInvalidUnit},
+		{"1kb", ErrInvalidUnit},
+		{"1 k", ErrInvalidUnit},
+		{"09", ErrInvalidUnit},
+		{"1_000", ErrInvalidUnit},
+		{"9223372036854775808", ErrOutOfRange},
+		{"-9223372036854775808", ErrOutOfRange},
+		{"8589934592g", ErrOutOfRange},
+		{"99999999999999999999", ErrOutOfRange},
+	} {
+		_, err := ParseInt64(tc.Value)
+		if e, ok := err.(*NumError); assert.True(ok, tc.Value) {
+			assert.Equal(tc.Err, e.Err, tc.Value)
+			assert.Equal(tc.Value, e.Value, tc.Value)
+		}
+	}
+}
+
+func TestParseUint64(t *testing.T) {
+	assert := assert.New(t)
+
+	result, err := ParseUint64("18446744073709551615")
+	assert.Nil(err)
+	assert.Equal(uint64(math.MaxUint64), result)
+	result, err = ParseUint64("16777215g")
+	assert.Nil(err)
+	assert.Equal(uint64(16777215*1024*1024*1024), result)
+
+	_, err = ParseUint64("-1")
+	if e, ok := err.(*NumError); assert.True(ok) {
+		assert.Equal(ErrInvalidUnit, e.Err)
+	}
+	_, err = ParseUint64("17179869184g")
+	if e, ok := err.(*NumError); assert.True(ok) {
+		assert.Equal(ErrOutOfRange, e.Err)
+	}
+}
+
+func TestGetIntWithUnit(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := NewGitConfig()
+	cfg.Add("core.bigFileThreshold", "512m")
+	cfg.Add("pack.windowMemory", "1g")
+	cfg.Add("http.postBuffer", "524288000k")
+	cfg.Add("core.bad", "1x")
+
+	v1, err := cfg.GetIntE("core.bigFileThreshold", 0)
+	assert.Nil(err)
+	assert.Equal(512*1024*1024, v1)
+	v2, err := cfg.GetInt64E("http.postBuffer", 0)
+	assert.Nil(err)
+	assert.Equal(int64(524288000*1024), v2)
+	v3, err := cfg.GetUint64E("pack.windowMemory", 0)
+	assert.Nil(err)
+	assert.Equal(uint64(1024*1024*1024), v3)
+
+	_, err = cfg.GetIntE("core.bad", 0)
+	assert.Equal(&NumError{Key: "core.bad", Value: "1x", Err: ErrInvalidUnit}, err)
+	assert.Equal("bad numeric config value '1x' for 'core.bad': invalid unit", err.Error())
+	assert.Equal(10, cfg.GetInt("core.bad", 10))
+}
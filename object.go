@@ -0,0 +1,636 @@
+package gitconfig
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of git objects, the same as object types in packfile
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+const (
+	// maxDeltaDepth limits length of delta chain in packfile
+	maxDeltaDepth = 10000
+	// maxAlternateDepth limits depth of nested alternates, the same as git
+	maxAlternateDepth = 5
+	// maxPeelDepth limits length of tag chain
+	maxPeelDepth = 100
+)
+
+var objectTypes = map[string]int{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+// objectTypeName returns name of object type
+func objectTypeName(objType int) string {
+	for name, t := range objectTypes {
+		if t == objType {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// objectStore reads objects from loose objects and packfiles in object
+// directories (including alternates) of a repository, without git.
+type objectStore struct {
+	fsys     FS
+	dirs     []string
+	hashSize int
+	packs    []*packFile
+	loaded   bool
+}
+
+// newObjectStore creates objectStore for objects in commonDir in fsys
+func newObjectStore(fsys FS, commonDir string, hashSize int) *objectStore {
+	v := &objectStore{fsys: fsys, hashSize: hashSize}
+	v.addDir(filepath.Join(commonDir, "objects"), 0)
+	return v
+}
+
+// addDir adds object directory and directories in its alternates
+func (v *objectStore) addDir(dir string, depth int) {
+	for _, d := range v.dirs {
+		if d == dir {
+			return
+		}
+	}
+	v.dirs = append(v.dirs, dir)
+	if depth >= maxAlternateDepth {
+		return
+	}
+	data, err := readFile(v.fsys, filepath.Join(dir, "info", "alternates"))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		v.addDir(filepath.Clean(line), depth+1)
+	}
+}
+
+// close closes opened packfiles
+func (v *objectStore) close() {
+	for _, pack := range v.packs {
+		pack.close()
+	}
+}
+
+// validObjectID checks whether oid is a full hex object id
+func (v *objectStore) validObjectID(oid string) bool {
+	return isObjectID(oid, v.hashSize)
+}
+
+// isObjectID checks whether oid is a full hex object id of hashSize
+func isObjectID(oid string, hashSize int) bool {
+	if len(oid) != hashSize*2 {
+		return false
+	}
+	_, err := hex.DecodeString(oid)
+	return err == nil
+}
+
+// readObject reads object by hex object id, and returns type and
+// contents of the object.
+func (v *objectStore) readObject(oid string) (int, []byte, error) {
+	return v.readObjectDepth(oid, 0)
+}
+
+func (v *objectStore) readObjectDepth(oid string, depth int) (int, []byte, error) {
+	if !v.validObjectID(oid) {
+		return 0, nil, fmt.Errorf("invalid object id '%s'", oid)
+	}
+	oid = strings.ToLower(oid)
+	for _, dir := range v.dirs {
+		data, err := readFile(v.fsys, filepath.Join(dir, oid[:2], oid[2:]))
+		if err == nil {
+			return parseLooseObject(oid, data)
+		}
+		if !os.IsNotExist(err) {
+			return 0, nil, err
+		}
+	}
+
+	if err := v.loadPacks(); err != nil {
+		return 0, nil, err
+	}
+	hash, _ := hex.DecodeString(oid)
+	for _, pack := range v.packs {
+		if offset, ok := pack.find(hash); ok {
+			return pack.readObject(v, offset, depth)
+		}
+	}
+	return 0, nil, fmt.Errorf("object %s not found", oid)
+}
+
+// loadPacks loads indexes of packfiles in object directories
+func (v *objectStore) loadPacks() error {
+	if v.loaded {
+		return nil
+	}
+	v.loaded = true
+	for _, dir := range v.dirs {
+		for _, name := range listPacks(v.fsys, dir) {
+			pack, err := loadPackIndex(v.fsys, filepath.Join(dir, "pack", name), v.hashSize)
+			if err != nil {
+				return err
+			}
+			v.packs = append(v.packs, pack)
+		}
+	}
+	return nil
+}
+
+// listPacks returns names of index files of packfiles in object
+// directory, "info/packs" is used if the directory cannot be listed.
+func listPacks(fsys FS, dir string) []string {
+	names := []string{}
+	if entries, err := readDir(fsys, filepath.Join(dir, "pack")); err == nil {
+		for _, fi := range entries {
+			if strings.HasPrefix(fi.Name(), "pack-") && strings.HasSuffix(fi.Name(), ".idx") {
+				names = append(names, fi.Name())
+			}
+		}
+		return names
+	}
+	data, err := readFile(fsys, filepath.Join(dir, "info", "packs"))
+	if err != nil {
+		return names
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "P ") && strings.HasSuffix(line, ".pack") {
+			names = append(names, strings.TrimSuffix(line[2:], ".pack")+".idx")
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseLooseObject inflates loose object, and parses its header
+func parseLooseObject(oid string, data []byte) (int, []byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, fmt.Errorf("corrupt loose object '%s': %s", oid, err)
+	}
+	defer r.Close()
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("corrupt loose object '%s': %s", oid, err)
+	}
+	pos := bytes.IndexByte(data, 0)
+	if pos < 0 {
+		return 0, nil, fmt.Errorf("corrupt loose object '%s': bad header", oid)
+	}
+	header := strings.SplitN(string(data[:pos]), " ", 2)
+	objType, ok := objectTypes[header[0]]
+	if !ok || len(header) != 2 {
+		return 0, nil, fmt.Errorf("corrupt loose object '%s': bad header", oid)
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(data)-pos-1 {
+		return 0, nil, fmt.Errorf("corrupt loose object '%s': bad size", oid)
+	}
+	return objType, data[pos+1:], nil
+}
+
+// packFile holds index of a packfile, and reads objects from it
+type packFile struct {
+	fsys     FS
+	name     string
+	hashSize int
+	fanout   [256]uint32
+	hashes   []byte
+	offsets  []uint64
+
+	reader io.ReaderAt
+	closer io.Closer
+}
+
+// loadPackIndex loads pack index file (version 1 or 2)
+func loadPackIndex(fsys FS, idxFile string, hashSize int) (*packFile, error) {
+	data, err := readFile(fsys, idxFile)
+	if err != nil {
+		return nil, err
+	}
+	v := &packFile{
+		fsys:     fsys,
+		name:     strings.TrimSuffix(idxFile, ".idx") + ".pack",
+		hashSize: hashSize,
+	}
+	corrupt := fmt.Errorf("corrupt pack index '%s'", idxFile)
+
+	version := 1
+	pos := 0
+	if len(data) >= 8 && bytes.Equal(data[:4], []byte("\377tOc")) {
+		version = int(binary.BigEndian.Uint32(data[4:8]))
+		if version != 2 {
+			return nil, fmt.Errorf("unsupported version %d of pack index '%s'", version, idxFile)
+		}
+		pos = 8
+	}
+	if len(data) < pos+256*4 {
+		return nil, corrupt
+	}
+	for i := 0; i < 256; i++ {
+		v.fanout[i] = binary.BigEndian.Uint32(data[pos+i*4:])
+		if i > 0 && v.fanout[i] < v.fanout[i-1] {
+			return nil, corrupt
+		}
+	}
+	pos += 256 * 4
+	n := int(v.fanout[255])
+
+	if version == 1 {
+		entrySize := 4 + hashSize
+		if len(data) < pos+n*entrySize {
+			return nil, corrupt
+		}
+		v.hashes = make([]byte, 0, n*hashSize)
+		v.offsets = make([]uint64, n)
+		for i := 0; i < n; i++ {
+			entry := data[pos+i*entrySize:]
+			v.offsets[i] = uint64(binary.BigEndian.Uint32(entry))
+			v.hashes = append(v.hashes, entry[4:4+hashSize]...)
+		}
+		return v, nil
+	}
+
+	// Version 2: hashes, crc32, 32-bit offsets, and 64-bit offsets
+	if len(data) < pos+n*(hashSize+8) {
+		return nil, corrupt
+	}
+	v.hashes = data[pos : pos+n*hashSize]
+	pos += n * (hashSize + 4)
+	largeOffsets := data[pos+n*4:]
+	v.offsets = make([]uint64, n)
+	for i := 0; i < n; i++ {
+		offset := binary.BigEndian.Uint32(data[pos+i*4:])
+		if offset&0x80000000 == 0 {
+			v.offsets[i] = uint64(offset)
+			continue
+		}
+		idx := int(offset&0x7fffffff) * 8
+		if len(largeOffsets) < idx+8 {
+			return nil, corrupt
+		}
+		v.offsets[i] = binary.BigEndian.Uint64(largeOffsets[idx:])
+	}
+	return v, nil
+}
+
+// find returns offset of object in packfile
+func (v *packFile) find(hash []byte) (uint64, bool) {
+	lo := 0
+	if hash[0] > 0 {
+		lo = int(v.fanout[hash[0]-1])
+	}
+	hi := int(v.fanout[hash[0]])
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch bytes.Compare(hash, v.hashes[mid*v.hashSize:(mid+1)*v.hashSize]) {
+		case 0:
+			return v.offsets[mid], true
+		case -1:
+			hi = mid
+		default:
+			lo = mid + 1
+		}
+	}
+	return 0, false
+}
+
+// open opens packfile for random access, packfile is read into memory
+// if file of the file system does not support io.ReaderAt.
+func (v *packFile) open() error {
+	if v.reader != nil {
+		return nil
+	}
+	f, err := openFile(v.fsys, v.name)
+	if err != nil {
+		return err
+	}
+	if r, ok := f.(io.ReaderAt); ok {
+		v.reader = r
+		v.closer = f
+		return nil
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	v.reader = bytes.NewReader(data)
+	return nil
+}
+
+func (v *packFile) close() {
+	if v.closer != nil {
+		v.closer.Close()
+	}
+	v.reader = nil
+	v.closer = nil
+}
+
+// readObject reads object at offset of packfile, and resolves deltas
+func (v *packFile) readObject(store *objectStore, offset uint64, depth int) (int, []byte, error) {
+	if depth > maxDeltaDepth {
+		return 0, nil, fmt.Errorf("delta chain too long in '%s'", v.name)
+	}
+	if err := v.open(); err != nil {
+		return 0, nil, err
+	}
+	if offset > math.MaxInt64 {
+		return 0, nil, fmt.Errorf("bad offset %d in '%s'", offset, v.name)
+	}
+	r := bufio.NewReader(io.NewSectionReader(v.reader, int64(offset), math.MaxInt64-int64(offset)))
+	corrupt := func(err error) (int, []byte, error) {
+		return 0, nil, fmt.Errorf("corrupt object at offset %d in '%s': %v", offset, v.name, err)
+	}
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return corrupt(err)
+	}
+	objType := int(c>>4) & 7
+	size := uint64(c & 0x0f)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return corrupt(err)
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var (
+		baseType int
+		base     []byte
+	)
+	switch objType {
+	case objCommit, objTree, objBlob, objTag:
+		data, err := inflate(r, size)
+		if err != nil {
+			return corrupt(err)
+		}
+		return objType, data, nil
+	case objOfsDelta:
+		if c, err = r.ReadByte(); err != nil {
+			return corrupt(err)
+		}
+		distance := uint64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return corrupt(err)
+			}
+			distance = ((distance + 1) << 7) | uint64(c&0x7f)
+		}
+		if distance == 0 || distance > offset {
+			return corrupt(fmt.Errorf("bad delta base offset"))
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return corrupt(err)
+		}
+		if baseType, base, err = v.readObject(store, offset-distance, depth+1); err != nil {
+			return 0, nil, err
+		}
+		if base, err = applyDelta(base, delta); err != nil {
+			return corrupt(err)
+		}
+		return baseType, base, nil
+	case objRefDelta:
+		hash := make([]byte, v.hashSize)
+		if _, err = io.ReadFull(r, hash); err != nil {
+			return corrupt(err)
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return corrupt(err)
+		}
+		if baseType, base, err = store.readObjectDepth(hex.EncodeToString(hash), depth+1); err != nil {
+			return 0, nil, err
+		}
+		if base, err = applyDelta(base, delta); err != nil {
+			return corrupt(err)
+		}
+		return baseType, base, nil
+	}
+	return corrupt(fmt.Errorf("unknown object type %d", objType))
+}
+
+// inflate reads zlib compressed data of given size. Size comes from
+// header of object, buffer grows while reading instead of allocating
+// the size in advance, so a corrupt header cannot exhaust memory.
+func inflate(r io.Reader, size uint64) ([]byte, error) {
+	if size >= math.MaxInt64 {
+		return nil, fmt.Errorf("bad object size %d", size)
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var buf bytes.Buffer
+	if _, err = io.Copy(&buf, io.LimitReader(zr, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	if uint64(buf.Len()) != size {
+		return nil, fmt.Errorf("object size mismatch, want %d, got %d", size, buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// deltaSize reads size in header of delta, and returns size and length
+// of the header.
+func deltaSize(delta []byte) (uint64, int, error) {
+	var size uint64
+
+	for i, shift := 0, uint(0); i < len(delta) && shift < 64; i, shift = i+1, shift+7 {
+		size |= uint64(delta[i]&0x7f) << shift
+		if delta[i]&0x80 == 0 {
+			return size, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("bad delta header")
+}
+
+// applyDelta creates object from base object and delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, n, err := deltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	delta = delta[n:]
+	if srcSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	dstSize, n, err := deltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	delta = delta[n:]
+	// Each opcode copies at most 0x10000 bytes
+	if dstSize > uint64(len(delta))*0x10000 {
+		return nil, fmt.Errorf("bad delta result size %d", dstSize)
+	}
+
+	capacity := dstSize
+	if limit := uint64(len(base) + len(delta)); capacity > limit {
+		capacity = limit
+	}
+	result := make([]byte, 0, capacity)
+	for len(delta) > 0 {
+		c := delta[0]
+		delta = delta[1:]
+		if c&0x80 != 0 {
+			// Copy from base object
+			var offset, size uint64
+			for i := uint(0); i < 7; i++ {
+				if c&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("bad copy in delta")
+			}
+			if uint64(len(result))+size > dstSize {
+				return nil, fmt.Errorf("delta result size mismatch")
+			}
+			result = append(result, base[offset:offset+size]...)
+		} else if c != 0 {
+			// Insert data in delta
+			if int(c) > len(delta) {
+				return nil, fmt.Errorf("truncated delta")
+			}
+			if uint64(len(result))+uint64(c) > dstSize {
+				return nil, fmt.Errorf("delta result size mismatch")
+			}
+			result = append(result, delta[:c]...)
+			delta = delta[c:]
+		} else {
+			return nil, fmt.Errorf("unexpected delta opcode 0")
+		}
+	}
+	if uint64(len(result)) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return result, nil
+}
+
+// peel reads object, and follows tags (and commit for tree) until an
+// object of type want is found. Object id and contents are returned.
+func (v *objectStore) peel(oid string, want int) (string, []byte, error) {
+	for i := 0; i < maxPeelDepth; i++ {
+		objType, data, err := v.readObject(oid)
+		if err != nil {
+			return "", nil, err
+		}
+		if objType == want {
+			return oid, data, nil
+		}
+		var field string
+		if objType == objTag {
+			field = "object "
+		} else if objType == objCommit && want == objTree {
+			field = "tree "
+		} else {
+			return "", nil, fmt.Errorf("object %s is a %s, not a %s",
+				oid, objectTypeName(objType), objectTypeName(want))
+		}
+		oid = ""
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, field) {
+				oid = strings.TrimPrefix(line, field)
+				break
+			}
+		}
+		if oid == "" {
+			return "", nil, fmt.Errorf("corrupt %s object", objectTypeName(objType))
+		}
+	}
+	return "", nil, fmt.Errorf("too many nested tags")
+}
+
+// parent returns the nth parent of commit, or commit itself if n is 0
+func (v *objectStore) parent(oid string, n int) (string, error) {
+	oid, data, err := v.peel(oid, objCommit)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return oid, nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "parent ") {
+			n--
+			if n == 0 {
+				return strings.TrimPrefix(line, "parent "), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("commit %s has no such parent", oid)
+}
+
+// treeEntry returns object id of entry name in tree
+func (v *objectStore) treeEntry(oid, name string) (string, bool, error) {
+	_, data, err := v.peel(oid, objTree)
+	if err != nil {
+		return "", false, err
+	}
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+1+v.hashSize {
+			return "", false, fmt.Errorf("corrupt tree object %s", oid)
+		}
+		if string(data[sp+1:nul]) == name {
+			return hex.EncodeToString(data[nul+1 : nul+1+v.hashSize]), true, nil
+		}
+		data = data[nul+1+v.hashSize:]
+	}
+	return "", false, nil
+}
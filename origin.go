@@ -0,0 +1,155 @@
+package gitconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Types of origin, the same as "git config --show-origin"
+const (
+	OriginFile        = "file"
+	OriginBlob        = "blob"
+	OriginCommandLine = "command line"
+	OriginStdin       = "standard input"
+)
+
+// Origin tells where a config value comes from
+type Origin struct {
+	// Type is the type of origin, such as OriginFile
+	Type string
+	// Name is the file path or blob name
+	Name string
+	// Line is the line number of the value in the file
+	Line uint
+}
+
+// String shows origin like "git config --show-origin", e.g. "file:/etc/gitconfig"
+func (v Origin) String() string {
+	return v.Type + ":" + quoteOriginName(v.Name)
+}
+
+// quoteOriginName quotes name in C style if it has special characters
+func quoteOriginName(name string) string {
+	needQuote := false
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] == 0x7f || name[i] == '"' || name[i] == '\\' {
+			needQuote = true
+			break
+		}
+	}
+	if !needQuote {
+		return name
+	}
+
+	quoted := "\""
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch c {
+		case '"', '\\':
+			quoted += "\\" + string(c)
+		case '\a':
+			quoted += "\\a"
+		case '\b':
+			quoted += "\\b"
+		case '\f':
+			quoted += "\\f"
+		case '\n':
+			quoted += "\\n"
+		case '\r':
+			quoted += "\\r"
+		case '\t':
+			quoted += "\\t"
+		case '\v':
+			quoted += "\\v"
+		default:
+			if c < 0x20 || c == 0x7f {
+				quoted += fmt.Sprintf("\\%03o", c)
+			} else {
+				quoted += string(c)
+			}
+		}
+	}
+	return quoted + "\""
+}
+
+// gitName returns name of scope the same as "git config --show-scope".
+// Values from included files have the same scope as the including file.
+func (v scope) gitName() string {
+	if (v & ScopeSystem) == ScopeSystem {
+		return "system"
+	} else if (v & ScopeGlobal) == ScopeGlobal {
+		return "global"
+	} else if (v & ScopeSelf) == ScopeSelf {
+		return "local"
+	} else if (v & ScopeCommand) == ScopeCommand {
+		return "command"
+	} else if (v & ScopeWorktree) == ScopeWorktree {
+		return "worktree"
+	}
+	return "unknown"
+}
+
+// ConfigValue is a value of config variable with its scope and origin
+type ConfigValue struct {
+	// Key is the name of variable, with section and key in lower case
+	Key string
+	// Value is the value of variable
+	Value string
+	// Scope is the scope like "git config --show-scope", such as "global"
+	Scope string
+	// Origin tells where the value comes from
+	Origin Origin
+	// Included indicates value is from an included file
+	Included bool
+	// NoValue indicates variable has no value (e.g. "[core] bare"), which
+	// is true as boolean.
+	NoValue bool
+}
+
+// Origin is used to show where the value comes from
+func (v gitConfigValue) Origin() Origin {
+	return v.origin
+}
+
+// GetWithOrigin gets the last value of key with its scope and origin
+func (v GitConfig) GetWithOrigin(key string) (ConfigValue, bool) {
+	values := v.GetAllWithOrigin(key)
+	if len(values) == 0 {
+		return ConfigValue{}, false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAllWithOrigin gets all values of key with their scopes and origins
+func (v GitConfig) GetAllWithOrigin(key string) []ConfigValue {
+	section, k := toSectionKey(key)
+	if v[section] == nil || v[section][k] == nil {
+		return nil
+	}
+
+	name := k
+	if section != "" {
+		name = section + "." + k
+	}
+	result := []ConfigValue{}
+	for _, value := range v[section][k] {
+		result = append(result, ConfigValue{
+			Key:      name,
+			Value:    value.value,
+			Scope:    value.scope.gitName(),
+			Origin:   value.origin,
+			Included: (value.scope & ScopeInclude) != 0,
+			NoValue:  value.noValue,
+		})
+	}
+	return result
+}
+
+// String shows value like "git config --show-scope --show-origin --list"
+func (v ConfigValue) String() string {
+	line := v.Key + "=" + v.Value
+	if v.NoValue {
+		line = v.Key
+	}
+	return strings.Join([]string{v.Scope, v.Origin.String(), line}, "\t")
+}
@@ -0,0 +1,72 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWithOrigin(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome(tmpdir)
+
+	sysCfgFile := filepath.Join(tmpdir, "system-config")
+	os.Setenv(gitSystemConfigEnv, sysCfgFile)
+	defer os.Unsetenv(gitSystemConfigEnv)
+
+	incCfgFile := filepath.Join(tmpdir, "include\"d")
+	userCfgFile := filepath.Join(tmpdir, ".gitconfig")
+	workdir := filepath.Join(tmpdir, "workdir")
+	repoCfgFile := filepath.Join(workdir, ".git", "config")
+
+	assert.Nil(exec.Command("git", "config", "-f", sysCfgFile, "test.key", "sys").Run())
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "include.path", incCfgFile).Run())
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "--add", "test.key", "user").Run())
+	assert.Nil(exec.Command("git", "config", "-f", incCfgFile, "test.key", "included").Run())
+	assert.Nil(exec.Command("git", "init", "-q", workdir, "--").Run())
+	assert.Nil(exec.Command("git", "-C", workdir, "config", "test.Key", "repo").Run())
+
+	cfg, err := LoadDirWithDefault(workdir)
+	assert.Nil(err)
+
+	values := cfg.GetAllWithOrigin("Test.KEY")
+	assert.Equal([]ConfigValue{
+		{"test.key", "sys", "system", Origin{OriginFile, sysCfgFile, 2}, false, false},
+		{"test.key", "included", "global", Origin{OriginFile, incCfgFile, 2}, true, false},
+		{"test.key", "user", "global", Origin{OriginFile, userCfgFile, 4}, false, false},
+	}, values[:3])
+	assert.Equal("repo", values[3].Value)
+	assert.Equal("local", values[3].Scope)
+	assert.Equal(repoCfgFile, values[3].Origin.Name)
+
+	value, ok := cfg.GetWithOrigin("test.key")
+	assert.True(ok)
+	assert.Equal("local\tfile:"+repoCfgFile+"\ttest.key=repo", value.String())
+	assert.Equal("global\tfile:"+`"`+filepath.Join(tmpdir, `include\"d`)+`"`+"\ttest.key=included", values[1].String())
+
+	_, ok = cfg.GetWithOrigin("test.missing")
+	assert.False(ok)
+	assert.Nil(cfg.GetAllWithOrigin("test.missing"))
+}
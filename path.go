@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -11,8 +12,23 @@ import (
 
 const (
 	gitSystemConfigEnv = "TEST_GIT_SYSTEM_CONFIG"
+
+	// worktreeConfigName is the name of per-worktree config file in gitdir
+	worktreeConfigName = "config.worktree"
+
+	// Environments to override system and global config, the same as git
+	gitConfigSystemEnv   = "GIT_CONFIG_SYSTEM"
+	gitConfigGlobalEnv   = "GIT_CONFIG_GLOBAL"
+	gitConfigNoSystemEnv = "GIT_CONFIG_NOSYSTEM"
 )
 
+// SystemPrefix is the install prefix of git which "%(prefix)/" in a
+// pathname expands to. It is "/usr" by default, and can be changed at
+// runtime, or at build time by:
+//
+//	go build -ldflags "-X github.com/jiangxin/gitconfig.SystemPrefix=/opt/git"
+var SystemPrefix = "/usr"
+
 // homeDir returns home directory
 func homeDir() (string, error) {
 	var (
@@ -75,6 +91,55 @@ func expendHome(name string) (string, error) {
 	return filepath.Join(home, name), nil
 }
 
+// ExpandPath expands value of a pathname config variable the same as
+// git: "~/" to home dir, "~user/" to home dir of user, and "%(prefix)/"
+// to SystemPrefix. Other paths are returned as is.
+func ExpandPath(value string) (string, error) {
+	if strings.HasPrefix(value, "%(prefix)/") {
+		return filepath.Join(SystemPrefix, value[len("%(prefix)/"):]), nil
+	}
+	if !strings.HasPrefix(value, "~") {
+		return value, nil
+	}
+
+	name := value[1:]
+	rest := ""
+	if pos := strings.IndexAny(name, "/\\"); pos >= 0 {
+		name, rest = name[:pos], name[pos+1:]
+	}
+	if name == "" {
+		return expendHome("~/" + rest)
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand user dir in: '%s'", value)
+	}
+	return filepath.Join(u.HomeDir, rest), nil
+}
+
+// GetPath gets value of key as a pathname with default, "~/" and
+// "~user/" are expanded, see ExpandPath.
+func (v GitConfig) GetPath(key string, defaultValue string) string {
+	result, err := v.GetPathE(key, defaultValue)
+	if err != nil {
+		result = defaultValue
+	}
+	return result
+}
+
+// GetPathE gets value of key as a pathname with default with error
+func (v GitConfig) GetPathE(key string, defaultValue string) (string, error) {
+	values := v.getRaw(key)
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+	value := values[len(values)-1]
+	if value.noValue {
+		return "", ErrMissingValue
+	}
+	return ExpandPath(value.value)
+}
+
 // absPath returns absolute path and will expend homedir if path has "~/' prefix
 func absPath(name string) (string, error) {
 	if name == "" {
@@ -109,7 +174,7 @@ func absJoin(dir, name string) (string, error) {
 	return absPath(filepath.Join(dir, name))
 }
 
-func getWorkTree(gitDir string) (string, error) {
+func getWorkTree(fsys FS, gitDir string) (string, error) {
 	var err error
 
 	if !filepath.IsAbs(gitDir) {
@@ -120,7 +185,7 @@ func getWorkTree(gitDir string) (string, error) {
 	}
 	// A git-worktree gitdir?
 	fn := filepath.Join(gitDir, "gitdir")
-	f, err := os.Open(fn)
+	f, err := openFile(fsys, fn)
 	if err == nil {
 		s := bufio.NewScanner(f)
 		if s.Scan() {
@@ -138,10 +203,10 @@ func getWorkTree(gitDir string) (string, error) {
 	return filepath.Dir(gitDir), nil
 }
 
-func getGitCommonDir(gitDir string) (string, error) {
+func getGitCommonDir(fsys FS, gitDir string) (string, error) {
 	commonDir := gitDir
-	if IsFile(filepath.Join(gitDir, "commondir")) {
-		f, err := os.Open(filepath.Join(gitDir, "commondir"))
+	if isFile(fsys, filepath.Join(gitDir, "commondir")) {
+		f, err := openFile(fsys, filepath.Join(gitDir, "commondir"))
 		if err == nil {
 			s := bufio.NewScanner(f)
 			if s.Scan() {
@@ -154,26 +219,26 @@ func getGitCommonDir(gitDir string) (string, error) {
 		}
 	}
 
-	if IsFile(filepath.Join(commonDir, "config")) &&
-		IsDir(filepath.Join(commonDir, "refs")) &&
-		IsDir(filepath.Join(commonDir, "objects")) {
+	if isFile(fsys, filepath.Join(commonDir, "config")) &&
+		isDir(fsys, filepath.Join(commonDir, "refs")) &&
+		isDir(fsys, filepath.Join(commonDir, "objects")) {
 		return commonDir, nil
 	}
 	return "", fmt.Errorf("'%s' is not a valid gitdir", commonDir)
 }
 
 // isGitDir test whether dir is a valid git dir
-func isGitDir(dir string) bool {
-	if !IsFile(filepath.Join(dir, "HEAD")) {
+func isGitDir(fsys FS, dir string) bool {
+	if !isFile(fsys, filepath.Join(dir, "HEAD")) {
 		return false
 	}
 
-	_, err := getGitCommonDir(dir)
+	_, err := getGitCommonDir(fsys, dir)
 	return err == nil
 }
 
 // findGitDir searches git dir
-func findGitDir(dir string) (string, error) {
+func findGitDir(fsys FS, dir string) (string, error) {
 	var err error
 
 	dir, err = absPath(dir)
@@ -183,13 +248,13 @@ func findGitDir(dir string) (string, error) {
 
 	for {
 		// Check if is in a bare repo
-		if isGitDir(dir) {
+		if isGitDir(fsys, dir) {
 			return dir, nil
 		}
 
 		// Check .git
 		gitdir := filepath.Join(dir, ".git")
-		fi, err := os.Stat(gitdir)
+		fi, err := statFile(fsys, gitdir)
 		if err != nil {
 			// Test parent dir
 			oldDir := dir
@@ -199,12 +264,12 @@ func findGitDir(dir string) (string, error) {
 			}
 			continue
 		} else if fi.IsDir() {
-			if isGitDir(gitdir) {
+			if isGitDir(fsys, gitdir) {
 				return gitdir, nil
 			}
 			return "", fmt.Errorf("corrupt git dir: %s", gitdir)
 		} else {
-			f, err := os.Open(gitdir)
+			f, err := openFile(fsys, gitdir)
 			if err != nil {
 				return "", fmt.Errorf("cannot open gitdir file '%s'", gitdir)
 			}
@@ -219,7 +284,7 @@ func findGitDir(dir string) (string, error) {
 						return "", err
 					}
 				}
-				if isGitDir(realgit) {
+				if isGitDir(fsys, realgit) {
 					return realgit, nil
 				}
 				return "", fmt.Errorf("gitdir '%s' points to corrupt git repo: %s", gitdir, realgit)
@@ -234,17 +299,87 @@ func findGitDir(dir string) (string, error) {
 func FindGitConfig(dir string) (string, error) {
 	var err error
 
-	if dir, err = findGitDir(dir); err != nil {
+	fsys := GetFS()
+	if dir, err = findGitDir(fsys, dir); err != nil {
 		return "", err
 	}
-	if dir, err = getGitCommonDir(dir); err != nil {
+	if dir, err = getGitCommonDir(fsys, dir); err != nil {
 		return "", err
 	}
 	return filepath.Join(dir, "config"), nil
 }
 
-// SystemConfigFile returns system git config file
+// envBool returns boolean value of environment, or defaultValue if
+// the environment is not set. A bad value returns defaultValue with
+// error, which git dies on.
+func envBool(name string, defaultValue bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	result, err := ParseBool(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("bad boolean config value '%s' for '%s'", value, name)
+	}
+	return result, nil
+}
+
+// SystemConfigEnabled indicates whether system config should be loaded,
+// which is disabled by setting GIT_CONFIG_NOSYSTEM. Unlike git, a bad
+// value of GIT_CONFIG_NOSYSTEM is ignored, see SystemConfigEnabledE.
+func SystemConfigEnabled() bool {
+	enabled, _ := SystemConfigEnabledE()
+	return enabled
+}
+
+// SystemConfigEnabledE indicates whether system config should be loaded
+// with error for a bad value of GIT_CONFIG_NOSYSTEM. System config is
+// not loaded (see SystemConfig) if there is an error.
+func SystemConfigEnabledE() (bool, error) {
+	noSystem, err := envBool(gitConfigNoSystemEnv, false)
+	return !noSystem, err
+}
+
+// FindWorktreeConfig returns per-worktree config file ("config.worktree"
+// in gitdir). Like "git config --worktree", returns config file of the
+// repository if extensions.worktreeConfig is not enabled and there is
+// only one worktree.
+func FindWorktreeConfig(dir string) (string, error) {
+	var err error
+
+	fsys := GetFS()
+	if dir, err = findGitDir(fsys, dir); err != nil {
+		return "", err
+	}
+	commonDir, err := getGitCommonDir(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	repoConfig, err := loadFile(fsys, filepath.Join(commonDir, "config"), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if repoConfig.GetBool("extensions.worktreeConfig", false) {
+		return filepath.Join(dir, worktreeConfigName), nil
+	}
+	if dirs, err := readDir(fsys, filepath.Join(commonDir, "worktrees")); err == nil {
+		for _, fi := range dirs {
+			if fi.IsDir() {
+				return "", fmt.Errorf("--worktree cannot be used with multiple " +
+					"working trees unless the config extension " +
+					"worktreeConfig is enabled")
+			}
+		}
+	}
+	return filepath.Join(commonDir, "config"), nil
+}
+
+// SystemConfigFile returns system git config file, which can be
+// overridden by GIT_CONFIG_SYSTEM.
 func SystemConfigFile() string {
+	if file, ok := os.LookupEnv(gitConfigSystemEnv); ok {
+		return file
+	}
 	file := os.Getenv(gitSystemConfigEnv)
 	if file == "" {
 		file = "/etc/gitconfig"
@@ -252,26 +387,41 @@ func SystemConfigFile() string {
 	return file
 }
 
-// GlobalConfigFile returns global git config file
+// GlobalConfigFile returns global git config file to write, which can be
+// overridden by GIT_CONFIG_GLOBAL. Like git, use "~/.gitconfig" unless
+// it does not exist and XDG config file exists.
 func GlobalConfigFile() (string, error) {
-	var (
-		file string
-		err  error
-	)
-
-	file, err = xdgConfigHome("config")
+	files, err := GlobalConfigFiles()
 	if err != nil {
 		return "", err
 	}
+	file := files[len(files)-1]
+	if len(files) > 1 && !Exist(file) && Exist(files[0]) {
+		file = files[0]
+	}
+	return file, nil
+}
 
-	// xdg config not exist, use ~/.gitconfig
-	if _, err := os.Stat(file); err != nil {
-		file, err = expendHome(".gitconfig")
-		if err != nil {
-			return "", err
+// GlobalConfigFiles returns global git config files in the order git
+// reads them: XDG config file ("$XDG_CONFIG_HOME/git/config") first,
+// then "~/.gitconfig". If GIT_CONFIG_GLOBAL is set, only returns it.
+func GlobalConfigFiles() ([]string, error) {
+	if file, ok := os.LookupEnv(gitConfigGlobalEnv); ok {
+		if file == "" {
+			return nil, fmt.Errorf("%s may not be empty", gitConfigGlobalEnv)
 		}
+		return []string{file}, nil
 	}
-	return file, nil
+
+	xdgFile, err := xdgConfigHome("config")
+	if err != nil {
+		return nil, err
+	}
+	homeFile, err := expendHome(".gitconfig")
+	if err != nil {
+		return nil, err
+	}
+	return []string{xdgFile, homeFile}, nil
 }
 
 // unsetHome unsets HOME related environments
@@ -302,7 +452,7 @@ func setHome(home string) {
 
 // Exist check if path is exist.
 func Exist(name string) bool {
-	if _, err := os.Stat(name); err == nil {
+	if _, err := statFile(GetFS(), name); err == nil {
 		return true
 	}
 	return false
@@ -310,7 +460,12 @@ func Exist(name string) bool {
 
 // IsFile returns true if path is exist and is a file.
 func IsFile(name string) bool {
-	fi, err := os.Stat(name)
+	return isFile(GetFS(), name)
+}
+
+// isFile returns true if path is exist in fsys and is a file
+func isFile(fsys FS, name string) bool {
+	fi, err := statFile(fsys, name)
 	if err != nil || fi.IsDir() {
 		return false
 	}
@@ -319,7 +474,12 @@ func IsFile(name string) bool {
 
 // IsDir returns true if path is exist and is a directory.
 func IsDir(name string) bool {
-	fi, err := os.Stat(name)
+	return isDir(GetFS(), name)
+}
+
+// isDir returns true if path is exist in fsys and is a directory
+func isDir(fsys FS, name string) bool {
+	fi, err := statFile(fsys, name)
 	if err != nil || !fi.IsDir() {
 		return false
 	}
@@ -278,7 +278,7 @@ func TestFindGitDir(t *testing.T) {
 	gitdir = filepath.Join(tmpdir, "bare.git")
 	cmd := exec.Command("git", "init", "--bare", gitdir, "--")
 	assert.Nil(cmd.Run())
-	dir, err = findGitDir(gitdir)
+	dir, err = findGitDir(OSFS{}, gitdir)
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -287,7 +287,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Equal(filepath.Join(gitdir, "config"), cfg)
 
 	// find in: bare.git/objects/pack
-	dir, err = findGitDir(filepath.Join(gitdir, "objects", "pack"))
+	dir, err = findGitDir(OSFS{}, filepath.Join(gitdir, "objects", "pack"))
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -305,7 +305,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Nil(err)
 
 	// find in: repo2/a/b/c
-	dir, err = findGitDir(filepath.Join(repo2, "a", "b", "c"))
+	dir, err = findGitDir(OSFS{}, filepath.Join(repo2, "a", "b", "c"))
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -320,7 +320,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Nil(err)
 
 	// fail to find in repo2/a/b/c (bad gitdir file)
-	dir, err = findGitDir(filepath.Join(repo2, "a", "b", "c"))
+	dir, err = findGitDir(OSFS{}, filepath.Join(repo2, "a", "b", "c"))
 	assert.NotNil(err)
 	assert.Equal("", dir)
 
@@ -338,7 +338,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Nil(err)
 
 	// find in workdir
-	dir, err = findGitDir(workdir)
+	dir, err = findGitDir(OSFS{}, workdir)
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -347,7 +347,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Equal(filepath.Join(gitdir, "config"), cfg)
 
 	// find in workdir/.git
-	dir, err = findGitDir(gitdir)
+	dir, err = findGitDir(OSFS{}, gitdir)
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -356,7 +356,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Equal(filepath.Join(gitdir, "config"), cfg)
 
 	// find in workdir/.git
-	dir, err = findGitDir(filepath.Join(workdir, "a", "b", "c"))
+	dir, err = findGitDir(OSFS{}, filepath.Join(workdir, "a", "b", "c"))
 	assert.Nil(err)
 	assert.Equal(gitdir, dir)
 
@@ -365,7 +365,7 @@ func TestFindGitDir(t *testing.T) {
 	assert.Equal(filepath.Join(gitdir, "config"), cfg)
 
 	// fail to find in tmpdir
-	dir, err = findGitDir(tmpdir)
+	dir, err = findGitDir(OSFS{}, tmpdir)
 	assert.Equal("", dir)
 	assert.Equal(ErrNotInGitDir, err)
 
@@ -373,3 +373,116 @@ func TestFindGitDir(t *testing.T) {
 	assert.Equal(ErrNotInGitDir, err)
 	assert.Equal("", cfg)
 }
+
+func TestConfigFileEnv(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+		file   string
+	)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome("/home/user")
+
+	os.Setenv(gitSystemConfigEnv, "/test/gitconfig")
+	defer os.Unsetenv(gitSystemConfigEnv)
+	assert.Equal("/test/gitconfig", SystemConfigFile())
+	os.Setenv(gitConfigSystemEnv, "/path/to/system")
+	defer os.Unsetenv(gitConfigSystemEnv)
+	assert.Equal("/path/to/system", SystemConfigFile())
+
+	assert.True(SystemConfigEnabled())
+	for _, tc := range []struct {
+		Value   string
+		Enabled bool
+	}{
+		{"1", false},
+		{"true", false},
+		{"yes", false},
+		{"0", true},
+		{"false", true},
+		{"bad", true},
+	} {
+		os.Setenv(gitConfigNoSystemEnv, tc.Value)
+		assert.Equal(tc.Enabled, SystemConfigEnabled(), "GIT_CONFIG_NOSYSTEM=%s", tc.Value)
+		enabled, err := SystemConfigEnabledE()
+		assert.Equal(tc.Enabled, enabled, "GIT_CONFIG_NOSYSTEM=%s", tc.Value)
+		if tc.Value == "bad" {
+			assert.Equal("bad boolean config value 'bad' for 'GIT_CONFIG_NOSYSTEM'", err.Error())
+		} else {
+			assert.Nil(err, "GIT_CONFIG_NOSYSTEM=%s", tc.Value)
+		}
+	}
+	os.Setenv(gitConfigNoSystemEnv, "maybe")
+	_, err = SystemConfig()
+	assert.NotNil(err)
+	os.Unsetenv(gitConfigNoSystemEnv)
+
+	os.Setenv(gitConfigGlobalEnv, "/path/to/global")
+	defer os.Unsetenv(gitConfigGlobalEnv)
+	file, err = GlobalConfigFile()
+	assert.Nil(err)
+	assert.Equal("/path/to/global", file)
+
+	os.Setenv(gitConfigGlobalEnv, "")
+	_, err = GlobalConfigFile()
+	assert.NotNil(err)
+}
+
+func TestGetPath(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cfg    = NewGitConfig()
+	)
+
+	home, err := homeDir()
+	assert.Nil(err)
+	defer setHome(home)
+	setHome("/home/tester")
+
+	cfg.Set("test.home", "~/a/b")
+	cfg.Set("test.abs", "/a/b")
+	cfg.Set("test.rel", "a/b")
+	cfg.Set("test.nouser", "~no-such-user-for-test/a")
+	cfg.addNoValue("test", "novalue", Origin{})
+
+	name, err := cfg.GetPathE("test.home", "")
+	assert.Nil(err)
+	assert.Equal(filepath.Join("/home/tester", "a", "b"), name)
+
+	name, err = cfg.GetPathE("test.abs", "")
+	assert.Nil(err)
+	assert.Equal("/a/b", name)
+
+	name, err = cfg.GetPathE("test.rel", "")
+	assert.Nil(err)
+	assert.Equal("a/b", name)
+
+	name, err = cfg.GetPathE("test.missing", "default")
+	assert.Nil(err)
+	assert.Equal("default", name)
+
+	_, err = cfg.GetPathE("test.nouser", "")
+	assert.Equal("failed to expand user dir in: '~no-such-user-for-test/a'", err.Error())
+
+	_, err = cfg.GetPathE("test.novalue", "")
+	assert.Equal(ErrMissingValue, err)
+
+	assert.Equal("/a/b", cfg.GetPath("test.abs", "default"))
+	assert.Equal("default", cfg.GetPath("test.nouser", "default"))
+
+	name, err = ExpandPath("%(prefix)/share/git-core")
+	assert.Nil(err)
+	assert.Equal(filepath.Join("/usr", "share", "git-core"), name)
+
+	defer func(prefix string) { SystemPrefix = prefix }(SystemPrefix)
+	SystemPrefix = "/opt/git"
+	name, err = ExpandPath("%(prefix)/etc/gitconfig")
+	assert.Nil(err)
+	assert.Equal(filepath.Join("/opt/git", "etc", "gitconfig"), name)
+}
@@ -0,0 +1,473 @@
+package gitconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefSpec is a parsed refspec, such as "+refs/heads/*:refs/remotes/origin/*"
+type RefSpec struct {
+	// Force is true if refspec starts with "+"
+	Force bool
+	// Negative is true if refspec starts with "^", which excludes refs
+	Negative bool
+	Src      string
+	Dst      string
+}
+
+// ParseRefSpec parses refspec of "remote.<name>.fetch" or "remote.<name>.push"
+func ParseRefSpec(spec string) (RefSpec, error) {
+	refspec := RefSpec{}
+	value := spec
+	if strings.HasPrefix(value, "+") {
+		refspec.Force = true
+		value = value[1:]
+	} else if strings.HasPrefix(value, "^") {
+		refspec.Negative = true
+		value = value[1:]
+	}
+	if pos := strings.LastIndex(value, ":"); pos >= 0 {
+		refspec.Src = value[:pos]
+		refspec.Dst = value[pos+1:]
+		if refspec.Src == "" && refspec.Dst == "" && !refspec.Negative {
+			// ":" or "+:" is for matching refs in push
+			return refspec, nil
+		}
+	} else {
+		refspec.Src = value
+	}
+
+	if refspec.Negative && (refspec.Dst != "" || refspec.Src == "") {
+		return RefSpec{}, fmt.Errorf("invalid negative refspec '%s'", spec)
+	}
+	if refspec.Src == "" && refspec.Dst == "" {
+		return RefSpec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	srcStars := strings.Count(refspec.Src, "*")
+	dstStars := strings.Count(refspec.Dst, "*")
+	if srcStars > 1 || dstStars > 1 ||
+		(refspec.Dst != "" && refspec.Src != "" && srcStars != dstStars) {
+		return RefSpec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	return refspec, nil
+}
+
+// String returns refspec in the form used in config
+func (v RefSpec) String() string {
+	prefix := ""
+	if v.Force {
+		prefix = "+"
+	} else if v.Negative {
+		prefix = "^"
+	}
+	if v.Dst == "" && v.Src != "" {
+		return prefix + v.Src
+	}
+	return prefix + v.Src + ":" + v.Dst
+}
+
+// IsWildcard tells whether refspec is a pattern which has "*"
+func (v RefSpec) IsWildcard() bool {
+	return strings.Contains(v.Src, "*") || strings.Contains(v.Dst, "*")
+}
+
+// Remote holds settings of "remote.<name>"
+type Remote struct {
+	Name     string
+	URLs     []string
+	PushURLs []string
+	Fetch    []RefSpec
+	Push     []RefSpec
+	// TagOpt is "--tags", "--no-tags" or empty
+	TagOpt string
+	// Prune is from "remote.<name>.prune", or "fetch.prune" if not set
+	Prune bool
+}
+
+// RebaseMode is the mode of "branch.<name>.rebase" and "pull.rebase"
+type RebaseMode string
+
+// Modes of rebase when pulling
+const (
+	RebaseFalse       RebaseMode = "false"
+	RebaseTrue        RebaseMode = "true"
+	RebaseMerges      RebaseMode = "merges"
+	RebaseInteractive RebaseMode = "interactive"
+)
+
+// parseRebaseMode parses value of rebase mode, the same as git
+func parseRebaseMode(value string) (RebaseMode, bool) {
+	switch strings.ToLower(value) {
+	case "merges", "m":
+		return RebaseMerges, true
+	case "interactive", "i":
+		return RebaseInteractive, true
+	}
+	result, err := ParseBool(value)
+	if err != nil {
+		return RebaseFalse, false
+	}
+	if result {
+		return RebaseTrue, true
+	}
+	return RebaseFalse, true
+}
+
+// Branch holds settings of "branch.<name>"
+type Branch struct {
+	Name string
+	// Remote is the upstream remote
+	Remote string
+	// PushRemote is the remote to push to, which overrides Remote
+	PushRemote string
+	// Merge is the upstream branch on the remote, e.g. "refs/heads/main"
+	Merge string
+	// Rebase is from "branch.<name>.rebase", or "pull.rebase" if not set
+	Rebase RebaseMode
+}
+
+// validName checks name of remote or branch, which is part of a refname
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "-") || strings.Contains(name, "..") {
+		return false
+	}
+	return !strings.ContainsAny(name, " \t\r\n:?*[\\^~")
+}
+
+// hasSection checks whether section has any values, keys which are
+// unset are left empty in the section.
+func (v GitConfig) hasSection(section string) bool {
+	for _, values := range v[section] {
+		if len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// subsections returns names of subsections of section in sorted order
+func (v GitConfig) subsections(section string) []string {
+	names := []string{}
+	for _, s := range v.Sections() {
+		if strings.HasPrefix(s, section+".") && v.hasSection(s) {
+			names = append(names, strings.TrimPrefix(s, section+"."))
+		}
+	}
+	return names
+}
+
+// setValues sets values of key, and does nothing if not changed. Only
+// values of ScopeSelf are changed: values inherited from other scopes
+// (e.g. global config) are kept as is and not copied to ScopeSelf.
+func (v GitConfig) setValues(key string, values []string) {
+	inherited := map[string]int{}
+	current := []string{}
+	for _, value := range v.getRaw(key) {
+		if value.scope == ScopeSelf {
+			current = append(current, value.value)
+		} else {
+			inherited[value.value]++
+		}
+	}
+
+	selfValues := []string{}
+	for _, value := range values {
+		if inherited[value] > 0 {
+			inherited[value]--
+			continue
+		}
+		selfValues = append(selfValues, value)
+	}
+
+	if len(current) == len(selfValues) {
+		same := true
+		for i := range selfValues {
+			if current[i] != selfValues[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	v.UnsetAll(key)
+	for _, value := range selfValues {
+		v.Add(key, value)
+	}
+}
+
+// setValue sets value of key, and unsets key if value is empty
+func (v GitConfig) setValue(key, value string) {
+	if value == "" {
+		v.setValues(key, nil)
+	} else {
+		v.setValues(key, []string{value})
+	}
+}
+
+// setDefaultValue sets value of key only if key is set or value is
+// different from defaultValue, which is the value in effect without
+// the key.
+func (v GitConfig) setDefaultValue(key, value, defaultValue string) {
+	if len(v.GetAll(key)) == 0 && value == defaultValue {
+		return
+	}
+	if v.Get(key) != value {
+		v.Set(key, value)
+	}
+}
+
+// Remotes returns all remotes sorted by name
+func (v GitConfig) Remotes() []Remote {
+	remotes := []Remote{}
+	for _, name := range v.subsections("remote") {
+		remote, _ := v.Remote(name)
+		remotes = append(remotes, remote)
+	}
+	return remotes
+}
+
+// Remote returns settings of "remote.<name>". Bad refspecs are ignored.
+func (v GitConfig) Remote(name string) (Remote, bool) {
+	section := "remote." + name
+	if !v.hasSection(section) {
+		return Remote{}, false
+	}
+	remote := Remote{
+		Name:     name,
+		URLs:     v.GetAll(section + ".url"),
+		PushURLs: v.GetAll(section + ".pushurl"),
+		Fetch:    []RefSpec{},
+		Push:     []RefSpec{},
+		TagOpt:   v.Get(section + ".tagopt"),
+		Prune:    v.GetBool(section+".prune", v.GetBool("fetch.prune", false)),
+	}
+	if remote.URLs == nil {
+		remote.URLs = []string{}
+	}
+	if remote.PushURLs == nil {
+		remote.PushURLs = []string{}
+	}
+	for _, spec := range v.GetAll(section + ".fetch") {
+		if refspec, err := ParseRefSpec(spec); err == nil {
+			remote.Fetch = append(remote.Fetch, refspec)
+		}
+	}
+	for _, spec := range v.GetAll(section + ".push") {
+		if refspec, err := ParseRefSpec(spec); err == nil {
+			remote.Push = append(remote.Push, refspec)
+		}
+	}
+	return remote, true
+}
+
+// SetRemote writes settings of remote to "remote.<name>", only changed
+// variables are touched.
+func (v GitConfig) SetRemote(remote Remote) error {
+	if !validName(remote.Name) {
+		return fmt.Errorf("invalid remote name '%s'", remote.Name)
+	}
+	section := "remote." + remote.Name
+	fetch := []string{}
+	for _, refspec := range remote.Fetch {
+		fetch = append(fetch, refspec.String())
+	}
+	push := []string{}
+	for _, refspec := range remote.Push {
+		push = append(push, refspec.String())
+	}
+
+	v.setValues(section+".url", remote.URLs)
+	v.setValues(section+".pushurl", remote.PushURLs)
+	v.setValues(section+".fetch", fetch)
+	v.setValues(section+".push", push)
+	v.setValue(section+".tagopt", remote.TagOpt)
+	// Keep spelling of the old value, such as "yes"
+	if prune, err := v.GetBoolE(section+".prune", !remote.Prune); err != nil || prune != remote.Prune {
+		v.setDefaultValue(section+".prune",
+			toString(remote.Prune),
+			toString(v.GetBool("fetch.prune", false)))
+	}
+	return nil
+}
+
+// Branches returns all branches which have settings, sorted by name
+func (v GitConfig) Branches() []Branch {
+	branches := []Branch{}
+	for _, name := range v.subsections("branch") {
+		branch, _ := v.Branch(name)
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+// Branch returns settings of "branch.<name>"
+func (v GitConfig) Branch(name string) (Branch, bool) {
+	section := "branch." + name
+	if !v.hasSection(section) {
+		return Branch{}, false
+	}
+	branch := Branch{
+		Name:       name,
+		Remote:     v.Get(section + ".remote"),
+		PushRemote: v.Get(section + ".pushremote"),
+		Merge:      v.Get(section + ".merge"),
+		Rebase:     v.defaultRebaseMode(),
+	}
+	if value := v.GetAll(section + ".rebase"); len(value) > 0 {
+		if mode, ok := parseRebaseMode(value[len(value)-1]); ok {
+			branch.Rebase = mode
+		}
+	}
+	return branch, true
+}
+
+// defaultRebaseMode returns rebase mode of "pull.rebase"
+func (v GitConfig) defaultRebaseMode() RebaseMode {
+	if value := v.GetAll("pull.rebase"); len(value) > 0 {
+		if mode, ok := parseRebaseMode(value[len(value)-1]); ok {
+			return mode
+		}
+	}
+	return RebaseFalse
+}
+
+// SetBranch writes settings of branch to "branch.<name>", only changed
+// variables are touched.
+func (v GitConfig) SetBranch(branch Branch) error {
+	if !validName(branch.Name) {
+		return fmt.Errorf("invalid branch name '%s'", branch.Name)
+	}
+	rebase := branch.Rebase
+	if rebase == "" {
+		rebase = RebaseFalse
+	}
+	if _, ok := parseRebaseMode(string(rebase)); !ok {
+		return fmt.Errorf("invalid rebase mode '%s'", rebase)
+	}
+	section := "branch." + branch.Name
+
+	v.setValue(section+".remote", branch.Remote)
+	v.setValue(section+".pushremote", branch.PushRemote)
+	v.setValue(section+".merge", branch.Merge)
+	if value := v.GetAll(section + ".rebase"); len(value) > 0 {
+		// Keep spelling of the old value, such as "yes"
+		if mode, ok := parseRebaseMode(value[len(value)-1]); ok && mode == rebase {
+			return nil
+		}
+	}
+	v.setDefaultValue(section+".rebase", string(rebase), string(v.defaultRebaseMode()))
+	return nil
+}
+
+// Remotes returns remotes of the repository
+func (v Repository) Remotes() []Remote {
+	return v.gitConfig.Remotes()
+}
+
+// Remote returns remote of the repository by name
+func (v Repository) Remote(name string) (Remote, bool) {
+	return v.gitConfig.Remote(name)
+}
+
+// Branches returns branches of the repository which have settings
+func (v Repository) Branches() []Branch {
+	return v.gitConfig.Branches()
+}
+
+// Branch returns settings of branch of the repository by name
+func (v Repository) Branch(name string) (Branch, bool) {
+	return v.gitConfig.Branch(name)
+}
+
+// urlRewrite is a rule to rewrite URLs which start with insteadOf to base
+type urlRewrite struct {
+	base      string
+	insteadOf string
+}
+
+// urlRewrites returns rules of "url.<base>.<key>", key is "insteadof"
+// or "pushinsteadof".
+func (v GitConfig) urlRewrites(key string) []urlRewrite {
+	rewrites := []urlRewrite{}
+	for _, section := range v.Sections() {
+		if !strings.HasPrefix(section, "url.") {
+			continue
+		}
+		base := strings.TrimPrefix(section, "url.")
+		for _, insteadOf := range v.GetAll(section + "." + key) {
+			rewrites = append(rewrites, urlRewrite{base: base, insteadOf: insteadOf})
+		}
+	}
+	return rewrites
+}
+
+// aliasURL rewrites url by the rule with the longest matched prefix,
+// the same as git.
+func aliasURL(url string, rewrites []urlRewrite) (string, bool) {
+	var longest *urlRewrite
+	for i := range rewrites {
+		if !strings.HasPrefix(url, rewrites[i].insteadOf) {
+			continue
+		}
+		if longest == nil || len(rewrites[i].insteadOf) > len(longest.insteadOf) {
+			longest = &rewrites[i]
+		}
+	}
+	if longest == nil {
+		return url, false
+	}
+	return longest.base + url[len(longest.insteadOf):], true
+}
+
+// RewriteURL rewrites url using "url.<base>.insteadOf", the longest
+// matched value wins.
+func (v GitConfig) RewriteURL(url string) string {
+	url, _ = aliasURL(url, v.urlRewrites("insteadof"))
+	return url
+}
+
+// RewritePushURL rewrites url for push using "url.<base>.pushInsteadOf",
+// or "url.<base>.insteadOf" if no pushInsteadOf matches. It is for URLs
+// from "remote.<name>.url", URLs from "remote.<name>.pushurl" are only
+// rewritten by RewriteURL.
+func (v GitConfig) RewritePushURL(url string) string {
+	if alias, ok := aliasURL(url, v.urlRewrites("pushinsteadof")); ok {
+		return alias
+	}
+	return v.RewriteURL(url)
+}
+
+// RemoteURLs returns URLs of remote used for fetch and push after
+// rewritten, the same as git. If remote has no push URLs, push URLs are
+// the URLs matching "pushInsteadOf", or the same as fetch URLs if none
+// of them matches.
+func (v GitConfig) RemoteURLs(remote Remote) (fetchURLs, pushURLs []string) {
+	rewrites := v.urlRewrites("insteadof")
+	fetchURLs = []string{}
+	pushURLs = []string{}
+	for _, url := range remote.PushURLs {
+		url, _ = aliasURL(url, rewrites)
+		pushURLs = append(pushURLs, url)
+	}
+	pushRewrites := v.urlRewrites("pushinsteadof")
+	for _, url := range remote.URLs {
+		if len(remote.PushURLs) == 0 {
+			if alias, ok := aliasURL(url, pushRewrites); ok {
+				pushURLs = append(pushURLs, alias)
+			}
+		}
+		url, _ = aliasURL(url, rewrites)
+		fetchURLs = append(fetchURLs, url)
+	}
+	if len(pushURLs) == 0 {
+		pushURLs = append(pushURLs, fetchURLs...)
+	}
+	return fetchURLs, pushURLs
+}
+
+// RemoteURLs returns rewritten URLs of remote for fetch and push
+func (v Repository) RemoteURLs(remote Remote) (fetchURLs, pushURLs []string) {
+	return v.gitConfig.RemoteURLs(remote)
+}
@@ -0,0 +1,341 @@
+package gitconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	testspace "github.com/Jiu2015/gotestspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRefSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		Spec    string
+		RefSpec RefSpec
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", RefSpec{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}},
+		{"refs/heads/main:refs/remotes/origin/main", RefSpec{Src: "refs/heads/main", Dst: "refs/remotes/origin/main"}},
+		{"refs/tags/v1", RefSpec{Src: "refs/tags/v1"}},
+		{"^refs/heads/tmp/*", RefSpec{Negative: true, Src: "refs/heads/tmp/*"}},
+		{":refs/heads/old", RefSpec{Dst: "refs/heads/old"}},
+		{":", RefSpec{}},
+		{"+:", RefSpec{Force: true}},
+	} {
+		refspec, err := ParseRefSpec(tc.Spec)
+		if assert.Nil(err, tc.Spec) {
+			assert.Equal(tc.RefSpec, refspec, tc.Spec)
+			assert.Equal(tc.Spec, refspec.String(), tc.Spec)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"+",
+		"^",
+		"^refs/heads/a:refs/heads/b",
+		"refs/heads/*:refs/remotes/origin/main",
+		"refs/heads/*/*:refs/remotes/origin/*/*",
+	} {
+		_, err := ParseRefSpec(spec)
+		assert.NotNil(err, spec)
+	}
+}
+
+func TestRemotesAndBranches(t *testing.T) {
+	assert := assert.New(t)
+
+	ws, err := testspace.Create(
+		testspace.WithPathOption("testspace-*"),
+		testspace.WithShellOption(`
+			git init -q --initial-branch=main repo &&
+			cd repo &&
+			git remote add origin https://example.com/repo.git &&
+			git remote add -t main --no-tags upstream https://example.com/upstream.git &&
+			git config --add remote.upstream.url https://mirror.example.com/upstream.git &&
+			git config remote.upstream.pushurl ssh://example.com/upstream.git &&
+			git config remote.upstream.push "+refs/heads/*:refs/heads/*" &&
+			git config remote.upstream.prune false &&
+			git config fetch.prune true &&
+			git config pull.rebase merges &&
+			git config branch.main.remote origin &&
+			git config branch.main.merge refs/heads/main &&
+			git config branch.dev.remote upstream &&
+			git config branch.dev.pushRemote origin &&
+			git config branch.dev.merge refs/heads/dev &&
+			git config branch.dev.rebase i
+		`),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	defer ws.Cleanup()
+
+	repo, err := FindRepository(ws.GetPath("repo"))
+	if !assert.Nil(err) {
+		return
+	}
+
+	origin := Remote{
+		Name:     "origin",
+		URLs:     []string{"https://example.com/repo.git"},
+		PushURLs: []string{},
+		Fetch: []RefSpec{
+			{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"},
+		},
+		Push:  []RefSpec{},
+		Prune: true,
+	}
+	upstream := Remote{
+		Name:     "upstream",
+		URLs:     []string{"https://example.com/upstream.git", "https://mirror.example.com/upstream.git"},
+		PushURLs: []string{"ssh://example.com/upstream.git"},
+		Fetch: []RefSpec{
+			{Force: true, Src: "refs/heads/main", Dst: "refs/remotes/upstream/main"},
+		},
+		Push: []RefSpec{
+			{Force: true, Src: "refs/heads/*", Dst: "refs/heads/*"},
+		},
+		TagOpt: "--no-tags",
+	}
+	assert.Equal([]Remote{origin, upstream}, repo.Remotes())
+	remote, ok := repo.Remote("upstream")
+	assert.True(ok)
+	assert.Equal(upstream, remote)
+	_, ok = repo.Remote("missing")
+	assert.False(ok)
+
+	dev := Branch{
+		Name:       "dev",
+		Remote:     "upstream",
+		PushRemote: "origin",
+		Merge:      "refs/heads/dev",
+		Rebase:     RebaseInteractive,
+	}
+	main := Branch{
+		Name:   "main",
+		Remote: "origin",
+		Merge:  "refs/heads/main",
+		Rebase: RebaseMerges,
+	}
+	assert.Equal([]Branch{dev, main}, repo.Branches())
+	branch, ok := repo.Branch("main")
+	assert.True(ok)
+	assert.Equal(main, branch)
+	_, ok = repo.Branch("missing")
+	assert.False(ok)
+}
+
+func TestSetRemoteAndBranch(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := NewGitConfig()
+	cfg.Add("fetch.prune", "true")
+	cfg.Add("remote.origin.url", "https://example.com/repo.git")
+	cfg.Add("remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+	cfg.Add("remote.origin.prune", "yes")
+
+	// Unchanged remote does not touch config
+	expected := cfg.String()
+	remote, ok := cfg.Remote("origin")
+	assert.True(ok)
+	assert.Nil(cfg.SetRemote(remote))
+	assert.Equal(expected, cfg.String())
+
+	remote.PushURLs = []string{"ssh://example.com/repo.git"}
+	remote.Fetch = append(remote.Fetch, RefSpec{Src: "refs/tags/*", Dst: "refs/tags/*"})
+	remote.TagOpt = "--tags"
+	remote.Prune = false
+	assert.Nil(cfg.SetRemote(remote))
+	assert.Equal([]string{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"refs/tags/*:refs/tags/*",
+	}, cfg.GetAll("remote.origin.fetch"))
+	assert.Equal("ssh://example.com/repo.git", cfg.Get("remote.origin.pushurl"))
+	assert.Equal("--tags", cfg.Get("remote.origin.tagopt"))
+	assert.Equal("false", cfg.Get("remote.origin.prune"))
+	actual, _ := cfg.Remote("origin")
+	assert.Equal(remote, actual)
+
+	// New remote, prune is same as fetch.prune and is not written
+	assert.Nil(cfg.SetRemote(Remote{
+		Name:  "upstream",
+		URLs:  []string{"https://example.com/upstream.git"},
+		Prune: true,
+	}))
+	assert.Equal("https://example.com/upstream.git", cfg.Get("remote.upstream.url"))
+	assert.False(cfg.HasKey("remote.upstream.prune"))
+	assert.False(cfg.HasKey("remote.upstream.fetch"))
+
+	// Unset values
+	remote.PushURLs = nil
+	remote.TagOpt = ""
+	assert.Nil(cfg.SetRemote(remote))
+	assert.Equal(0, len(cfg.GetAll("remote.origin.pushurl")))
+	assert.Equal(0, len(cfg.GetAll("remote.origin.tagopt")))
+
+	assert.NotNil(cfg.SetRemote(Remote{Name: ""}))
+	assert.NotNil(cfg.SetRemote(Remote{Name: "bad name"}))
+
+	// Branches
+	assert.Nil(cfg.SetBranch(Branch{
+		Name:   "main",
+		Remote: "origin",
+		Merge:  "refs/heads/main",
+	}))
+	assert.Equal("origin", cfg.Get("branch.main.remote"))
+	assert.Equal("refs/heads/main", cfg.Get("branch.main.merge"))
+	assert.False(cfg.HasKey("branch.main.rebase"))
+	assert.False(cfg.HasKey("branch.main.pushremote"))
+
+	cfg.Add("branch.main.rebase", "yes")
+	branch, ok := cfg.Branch("main")
+	assert.True(ok)
+	assert.Equal(RebaseTrue, branch.Rebase)
+	assert.Nil(cfg.SetBranch(branch))
+	assert.Equal("yes", cfg.Get("branch.main.rebase"))
+
+	branch.Rebase = RebaseMerges
+	branch.PushRemote = "upstream"
+	assert.Nil(cfg.SetBranch(branch))
+	assert.Equal("merges", cfg.Get("branch.main.rebase"))
+	assert.Equal("upstream", cfg.Get("branch.main.pushremote"))
+	actualBranch, _ := cfg.Branch("main")
+	assert.Equal(branch, actualBranch)
+
+	assert.NotNil(cfg.SetBranch(Branch{Name: "main", Rebase: "bad"}))
+	assert.NotNil(cfg.SetBranch(Branch{Name: "a..b"}))
+}
+
+func TestSetRemoteWithGlobalConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	ws, err := testspace.Create(
+		testspace.WithPathOption("testspace-*"),
+		testspace.WithShellOption(`
+			git config -f global.config remote.origin.url https://example.com/global.git &&
+			git init -q --initial-branch=main repo &&
+			git -C repo config remote.origin.fetch "+refs/heads/*:refs/remotes/origin/*"
+		`),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	defer ws.Cleanup()
+
+	os.Setenv(gitConfigGlobalEnv, ws.GetPath("global.config"))
+	defer os.Unsetenv(gitConfigGlobalEnv)
+
+	cfg, err := LoadDirWithDefault(ws.GetPath("repo"))
+	if !assert.Nil(err) {
+		return
+	}
+	remote, ok := cfg.Remote("origin")
+	assert.True(ok)
+	assert.Equal([]string{"https://example.com/global.git"}, remote.URLs)
+
+	// URL from global config is not copied to config of the repository
+	remote.PushURLs = []string{"ssh://example.com/repo.git"}
+	assert.Nil(cfg.SetRemote(remote))
+	assert.Nil(cfg.Save(ws.GetPath("repo/.git/config")))
+	data, err := ioutil.ReadFile(ws.GetPath("repo/.git/config"))
+	assert.Nil(err)
+	assert.Contains(string(data), "pushurl = ssh://example.com/repo.git")
+	assert.NotContains(string(data), "global.git")
+
+	cfg, err = LoadDirWithDefault(ws.GetPath("repo"))
+	if assert.Nil(err) {
+		actual, _ := cfg.Remote("origin")
+		assert.Equal(remote, actual)
+	}
+
+	// New URL is added to config of the repository
+	remote.URLs = append(remote.URLs, "https://example.com/local.git")
+	assert.Nil(cfg.SetRemote(remote))
+	assert.Nil(cfg.Save(ws.GetPath("repo/.git/config")))
+	data, err = ioutil.ReadFile(ws.GetPath("repo/.git/config"))
+	assert.Nil(err)
+	assert.Contains(string(data), "url = https://example.com/local.git")
+	assert.NotContains(string(data), "global.git")
+}
+
+func TestRewriteURL(t *testing.T) {
+	assert := assert.New(t)
+
+	ws, err := testspace.Create(
+		testspace.WithPathOption("testspace-*"),
+		testspace.WithShellOption(`
+			git init -q --initial-branch=main repo &&
+			cd repo &&
+			git config url."https://mirror.example.com/".insteadOf "https://example.com/" &&
+			git config url."https://mirror.example.com/team/".insteadOf "https://example.com/team" &&
+			git config --add url."https://mirror.example.com/team/".insteadOf "team:" &&
+			git config url."ssh://git@example.com/".pushInsteadOf "https://example.com/" &&
+			git remote add origin https://example.com/team/repo.git &&
+			git config --add remote.origin.url https://other.example.com/repo.git &&
+			git remote add upstream team:upstream.git &&
+			git config remote.upstream.pushurl https://example.com/upstream.git &&
+			git remote add other https://other.example.com/other.git
+		`),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	defer ws.Cleanup()
+
+	repo, err := FindRepository(ws.GetPath("repo"))
+	if !assert.Nil(err) {
+		return
+	}
+	cfg := repo.Config()
+
+	assert.Equal("https://mirror.example.com/team//repo.git", cfg.RewriteURL("https://example.com/team/repo.git"))
+	assert.Equal("https://mirror.example.com/repo.git", cfg.RewriteURL("https://example.com/repo.git"))
+	assert.Equal("https://mirror.example.com/team/repo.git", cfg.RewriteURL("team:repo.git"))
+	assert.Equal("https://other.example.com/repo.git", cfg.RewriteURL("https://other.example.com/repo.git"))
+	assert.Equal("ssh://git@example.com/repo.git", cfg.RewritePushURL("https://example.com/repo.git"))
+	assert.Equal("https://mirror.example.com/team/repo.git", cfg.RewritePushURL("team:repo.git"))
+
+	for _, tc := range []struct {
+		Name      string
+		FetchURLs []string
+		PushURLs  []string
+	}{
+		{
+			"origin",
+			[]string{"https://mirror.example.com/team//repo.git", "https://other.example.com/repo.git"},
+			[]string{"ssh://git@example.com/team/repo.git"},
+		},
+		{
+			"upstream",
+			[]string{"https://mirror.example.com/team/upstream.git"},
+			[]string{"https://mirror.example.com/upstream.git"},
+		},
+		{
+			"other",
+			[]string{"https://other.example.com/other.git"},
+			[]string{"https://other.example.com/other.git"},
+		},
+	} {
+		remote, ok := repo.Remote(tc.Name)
+		if !assert.True(ok, tc.Name) {
+			continue
+		}
+		fetchURLs, pushURLs := repo.RemoteURLs(remote)
+		assert.Equal(tc.FetchURLs, fetchURLs, tc.Name)
+		assert.Equal(tc.PushURLs, pushURLs, tc.Name)
+
+		// Same as git
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		stdout, stderr, err := ws.Execute(cancelCtx,
+			"git -C repo remote get-url --all "+tc.Name+" && git -C repo remote get-url --push --all "+tc.Name)
+		cancel()
+		if assert.Nil(err, "stdout: %s\nstderr: %s", stdout, stderr) {
+			assert.Equal(strings.Join(append(fetchURLs, pushURLs...), "\n")+"\n", stdout, tc.Name)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import "path/filepath"
 
 // Repository defines struct for a Git repository.
 type Repository struct {
+	fsys         FS
 	gitDir       string
 	gitCommonDir string
 	workDir      string
@@ -37,6 +38,12 @@ func (v Repository) Config() GitConfig {
 
 // FindRepository locates repository object search from the given dir.
 func FindRepository(dir string) (*Repository, error) {
+	return FindRepositoryFS(GetFS(), dir)
+}
+
+// FindRepositoryFS locates repository in fsys like FindRepository. Files
+// of the repository, such as blobs and ".gitmodules", are read from fsys.
+func FindRepositoryFS(fsys FS, dir string) (*Repository, error) {
 	var (
 		gitDir    string
 		commonDir string
@@ -45,22 +52,21 @@ func FindRepository(dir string) (*Repository, error) {
 		err       error
 	)
 
-	gitDir, err = findGitDir(dir)
-	if err != nil {
-		return nil, err
-	}
-	commonDir, err = getGitCommonDir(gitDir)
+	gitDir, err = findGitDir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
-	gitConfig, err = LoadFileWithDefault(filepath.Join(commonDir, "config"))
+	commonDir, err = getGitCommonDir(fsys, gitDir)
 	if err != nil {
 		return nil, err
 	}
+	gitConfig, _ = loadFileWithDefault(fsys, filepath.Join(commonDir, "config"),
+		newIncludeContext(fsys, gitDir), nil)
 	if !gitConfig.GetBool("core.bare", false) {
-		workDir, _ = getWorkTree(gitDir)
+		workDir, _ = getWorkTree(fsys, gitDir)
 	}
 	return &Repository{
+		fsys:         fsys,
 		gitDir:       gitDir,
 		gitCommonDir: commonDir,
 		workDir:      workDir,
@@ -1,6 +1,7 @@
 package gitconfig
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 
@@ -62,6 +63,7 @@ func TestWithSubtest(t *testing.T) {
 
 	t.Run("T=1", subTestRepositoryIsBare)
 	t.Run("T=2", subTestRepositoryGitPath)
+	t.Run("T=3", subTestRepositoryWorktreeConfig)
 
 	// Tear-down
 	repoTestSpace.Cleanup()
@@ -157,3 +159,54 @@ func subTestRepositoryGitPath(t *testing.T) {
 
 	}
 }
+
+func subTestRepositoryWorktreeConfig(t *testing.T) {
+	var (
+		repo *Repository
+		err  error
+	)
+
+	// Multiple worktrees without extensions.worktreeConfig
+	_, err = FindWorktreeConfig(repoTestSpace.GetPath("topic1"))
+	assert.NotNil(t, err)
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, stderr, err := repoTestSpace.Execute(
+		cancelCtx,
+		`git -C workdir config extensions.worktreeConfig true &&
+		git -C workdir config test.key repo &&
+		git -C topic1 config --worktree test.key topic1`,
+	)
+	assert.Nil(t, err, "stdout: %s\nstderr: %s", stdout, stderr)
+
+	for _, tc := range []struct {
+		Path  string
+		Value string
+		Scope string
+	}{
+		{"workdir", "repo", "local"},
+		{"topic1/a/b", "topic1", "worktree"},
+		{"topic2", "repo", "local"},
+	} {
+		repo, err = FindRepository(repoTestSpace.GetPath(tc.Path))
+		if assert.Nil(t, err) {
+			value, ok := repo.Config().GetWithOrigin("test.key")
+			assert.True(t, ok)
+			assert.Equal(t, tc.Value, value.Value, "test.key of '%s'", tc.Path)
+			assert.Equal(t, tc.Scope, value.Scope, "scope of test.key of '%s'", tc.Path)
+		}
+
+		cfg, err := LoadDirWithDefault(repoTestSpace.GetPath(tc.Path))
+		if assert.Nil(t, err) {
+			assert.Equal(t, tc.Value, cfg.Get("test.key"))
+		}
+	}
+
+	file, err := FindWorktreeConfig(repoTestSpace.GetPath("topic1/a"))
+	if assert.Nil(t, err) {
+		file, _ = filepath.EvalSymlinks(file)
+		dir, _ := filepath.EvalSymlinks(repoTestSpace.GetPath("workdir/.git/worktrees/topic1"))
+		assert.Equal(t, filepath.Join(dir, "config.worktree"), file)
+	}
+}
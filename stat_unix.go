@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package gitconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns inode of file, or 0 if unknown
+func fileInode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
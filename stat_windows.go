@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package gitconfig
+
+import (
+	"os"
+)
+
+// fileInode returns 0, for inode is not available in os.FileInfo on Windows
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
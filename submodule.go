@@ -0,0 +1,210 @@
+package gitconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// gitmodulesFile is the name of file which defines submodules
+	gitmodulesFile = ".gitmodules"
+)
+
+// SubmoduleUpdate is update strategy of submodule
+type SubmoduleUpdate string
+
+// Update strategies of submodule, the same as "submodule.<name>.update"
+const (
+	SubmoduleUpdateCheckout SubmoduleUpdate = "checkout"
+	SubmoduleUpdateRebase   SubmoduleUpdate = "rebase"
+	SubmoduleUpdateMerge    SubmoduleUpdate = "merge"
+	SubmoduleUpdateNone     SubmoduleUpdate = "none"
+	// SubmoduleUpdateCommand runs a custom command, which is "!command"
+	SubmoduleUpdateCommand SubmoduleUpdate = "command"
+)
+
+// Submodule holds settings of a submodule. Settings are read from
+// ".gitmodules", and are overridden by "submodule.<name>.*" in config
+// of the repository, except path.
+type Submodule struct {
+	Name   string
+	Path   string
+	URL    string
+	Branch string
+	// Update is the update strategy, default is checkout
+	Update SubmoduleUpdate
+	// UpdateCommand is the command for SubmoduleUpdateCommand
+	UpdateCommand string
+	Shallow       bool
+	// Active tells whether the submodule is active, which is decided
+	// by "submodule.<name>.active", "submodule.active" or whether url is
+	// set in config, the same as git.
+	Active bool
+}
+
+// Submodules returns submodules defined in ".gitmodules" of the work
+// tree, or ".gitmodules" of HEAD if the repository is bare or the file
+// is missing in the work tree.
+func (v Repository) Submodules() ([]Submodule, error) {
+	var (
+		data   []byte
+		origin Origin
+		found  bool
+		err    error
+	)
+
+	// Like git, includes in ".gitmodules" are not processed, which may
+	// refer to files outside of the work tree.
+	if v.workDir != "" {
+		origin = Origin{Type: OriginFile, Name: filepath.Join(v.workDir, gitmodulesFile)}
+		data, err = readFile(v.fsys, origin.Name)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		found = err == nil
+	}
+	if !found {
+		origin = Origin{Type: OriginBlob, Name: "HEAD:" + gitmodulesFile}
+		data, err = v.ReadBlob(origin.Name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return []Submodule{}, nil
+			}
+			return nil, err
+		}
+	}
+	gitmodules, _, err := parseWithoutIncludes(data, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settings in config of repository have higher priority
+	cfg := NewGitConfig()
+	cfg.Merge(gitmodules, ScopeSelf)
+	cfg.Merge(v.gitConfig, ScopeSelf)
+
+	submodules := []Submodule{}
+	for _, section := range gitmodules.Sections() {
+		if !strings.HasPrefix(section, "submodule.") {
+			continue
+		}
+		name := strings.TrimPrefix(section, "submodule.")
+		path := gitmodules.Get(section + ".path")
+		if path == "" || !validSubmoduleName(name) {
+			continue
+		}
+
+		submodule := Submodule{
+			Name:    name,
+			Path:    path,
+			URL:     cfg.Get(section + ".url"),
+			Branch:  cfg.Get(section + ".branch"),
+			Update:  SubmoduleUpdateCheckout,
+			Shallow: cfg.GetBool(section+".shallow", false),
+		}
+		if update := v.submoduleUpdate(gitmodules, section); strings.HasPrefix(update, "!") {
+			submodule.Update = SubmoduleUpdateCommand
+			submodule.UpdateCommand = update[1:]
+		} else if update != "" {
+			submodule.Update = SubmoduleUpdate(update)
+		}
+		submodule.Active = v.isSubmoduleActive(name, path)
+		submodules = append(submodules, submodule)
+	}
+	return submodules, nil
+}
+
+// submoduleUpdate returns "submodule.<name>.update" from config or
+// ".gitmodules". Custom command ("!command") is accepted from config set
+// by user of any scope, but never from ".gitmodules" which comes with
+// the cloned repository (CVE-2019-19604).
+func (v Repository) submoduleUpdate(gitmodules GitConfig, section string) string {
+	update := gitmodules.Get(section + ".update")
+	if strings.HasPrefix(update, "!") {
+		update = ""
+	}
+	if values := v.gitConfig.getRaw(section + ".update"); len(values) > 0 {
+		update = values[len(values)-1].value
+	}
+	return update
+}
+
+// validSubmoduleName checks name of submodule, which should not be
+// empty or contain ".." as a path component, the same as git.
+func validSubmoduleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, item := range strings.FieldsFunc(name, func(c rune) bool {
+		return c == '/' || c == '\\'
+	}) {
+		if item == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// isSubmoduleActive checks whether submodule is active the same way as
+// git: "submodule.<name>.active" is checked first, then pathspecs in
+// "submodule.active", and finally whether "submodule.<name>.url" is set
+// in config of the repository.
+func (v Repository) isSubmoduleActive(name, path string) bool {
+	section := "submodule." + name
+	if v.gitConfig.HasKey(section + ".active") {
+		return v.gitConfig.GetBool(section+".active", false)
+	}
+	if pathspecs := v.gitConfig.GetAll("submodule.active"); len(pathspecs) > 0 {
+		return matchPathspecs(pathspecs, path)
+	}
+	return v.gitConfig.HasKey(section + ".url")
+}
+
+// excludePathspec checks whether pathspec is an excluded one which
+// starts with ":!", ":^" or ":(exclude)", and returns the pattern.
+func excludePathspec(pathspec string) (string, bool) {
+	for _, prefix := range []string{":!", ":^", ":(exclude)"} {
+		if strings.HasPrefix(pathspec, prefix) {
+			return strings.TrimPrefix(pathspec, prefix), true
+		}
+	}
+	return pathspec, false
+}
+
+// matchPathspecs checks whether path matches any of pathspecs, and does
+// not match any excluded pathspecs. If there are only excluded pathspecs,
+// other paths are matched.
+func matchPathspecs(pathspecs []string, path string) bool {
+	matched := true
+	for _, pathspec := range pathspecs {
+		if _, exclude := excludePathspec(pathspec); !exclude {
+			matched = false
+			break
+		}
+	}
+	for _, pathspec := range pathspecs {
+		pattern, exclude := excludePathspec(pathspec)
+		if !matchPathspec(pattern, path) {
+			continue
+		}
+		if exclude {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// matchPathspec matches path with a pathspec, which matches the path
+// itself, files in it, or paths matching it as a glob pattern.
+func matchPathspec(pathspec, path string) bool {
+	if pathspec == "" || pathspec == "." {
+		return true
+	}
+	pathspec = strings.TrimSuffix(pathspec, "/")
+	if pathspec == path || strings.HasPrefix(path, pathspec+"/") {
+		return true
+	}
+	return wildmatch(pathspec, path, 0)
+}
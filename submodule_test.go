@@ -0,0 +1,175 @@
+package gitconfig
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	testspace "github.com/Jiu2015/gotestspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmodules(t *testing.T) {
+	assert := assert.New(t)
+
+	ws, err := testspace.Create(
+		testspace.WithPathOption("testspace-*"),
+		testspace.WithShellOption(`
+			git init -q --initial-branch=main repo &&
+			cd repo &&
+			cat >.gitmodules <<-EOF &&
+			[submodule "lib"]
+				path = lib
+				url = https://example.com/lib.git
+				branch = main
+				shallow = true
+			[submodule "tools/Gen"]
+				path = tools/gen
+				url = ../gen.git
+				update = !make gen
+			[submodule "docs"]
+				path = docs
+				url = https://example.com/docs.git
+				update = rebase
+			[submodule "../evil"]
+				path = evil
+				url = https://example.com/evil.git
+			[submodule "nopath"]
+				url = https://example.com/nopath.git
+			[include]
+				path = extra.gitmodules
+			EOF
+			cat >extra.gitmodules <<-EOF &&
+			[submodule "extra"]
+				path = extra
+				url = https://example.com/extra.git
+			EOF
+			git add .gitmodules extra.gitmodules &&
+			test_tick &&
+			git commit -q -m initial &&
+			git config submodule.lib.url https://mirror.example.com/lib.git &&
+			git config submodule.docs.update none &&
+			git config submodule.docs.active false &&
+			cd .. &&
+			git clone -q --bare repo repo.git &&
+			git init -q --initial-branch=main empty
+		`),
+	)
+	if !assert.Nil(err) {
+		return
+	}
+	defer ws.Cleanup()
+
+	docs := Submodule{
+		Name:   "docs",
+		Path:   "docs",
+		URL:    "https://example.com/docs.git",
+		Update: SubmoduleUpdateRebase,
+	}
+	lib := Submodule{
+		Name:    "lib",
+		Path:    "lib",
+		URL:     "https://example.com/lib.git",
+		Branch:  "main",
+		Update:  SubmoduleUpdateCheckout,
+		Shallow: true,
+	}
+	// Custom command in .gitmodules is ignored
+	gen := Submodule{
+		Name:   "tools/Gen",
+		Path:   "tools/gen",
+		URL:    "../gen.git",
+		Update: SubmoduleUpdateCheckout,
+	}
+
+	// Bare repository, read .gitmodules from HEAD
+	repo, err := FindRepository(ws.GetPath("repo.git"))
+	if assert.Nil(err) {
+		submodules, err := repo.Submodules()
+		assert.Nil(err)
+		assert.Equal([]Submodule{docs, lib, gen}, submodules)
+	}
+
+	// Overridden by repository config
+	docs.Update = SubmoduleUpdateNone
+	lib.URL = "https://mirror.example.com/lib.git"
+	lib.Active = true
+	repo, err = FindRepository(ws.GetPath("repo"))
+	if assert.Nil(err) {
+		submodules, err := repo.Submodules()
+		assert.Nil(err)
+		assert.Equal([]Submodule{docs, lib, gen}, submodules)
+	}
+
+	// Custom command is accepted from config of any scope, but not
+	// from .gitmodules. Submodule is active if url is set in config,
+	// even if it is empty.
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout, stderr, err := ws.Execute(cancelCtx, `
+		git -C repo config submodule.tools/Gen.update "!make gen" &&
+		git config -f global.config submodule.lib.update "!make lib" &&
+		git -C repo config submodule.tools/Gen.url ""
+	`)
+	assert.Nil(err, "stdout: %s\nstderr: %s", stdout, stderr)
+	os.Setenv(gitConfigGlobalEnv, ws.GetPath("global.config"))
+	defer os.Unsetenv(gitConfigGlobalEnv)
+	lib.Update = SubmoduleUpdateCommand
+	lib.UpdateCommand = "make lib"
+	gen.Update = SubmoduleUpdateCommand
+	gen.UpdateCommand = "make gen"
+	gen.URL = ""
+	gen.Active = true
+	repo, err = FindRepository(ws.GetPath("repo"))
+	if assert.Nil(err) {
+		submodules, err := repo.Submodules()
+		assert.Nil(err)
+		assert.Equal([]Submodule{docs, lib, gen}, submodules)
+	}
+
+	// Active by pathspecs
+	stdout, stderr, err = ws.Execute(cancelCtx, `
+		git -C repo config --add submodule.active "tools/*" &&
+		git -C repo config --add submodule.active ":(exclude)lib"
+	`)
+	assert.Nil(err, "stdout: %s\nstderr: %s", stdout, stderr)
+	lib.Active = false
+	gen.Active = true
+	repo, err = FindRepository(ws.GetPath("repo"))
+	if assert.Nil(err) {
+		submodules, err := repo.Submodules()
+		assert.Nil(err)
+		assert.Equal([]Submodule{docs, lib, gen}, submodules)
+	}
+
+	// No submodules in a repository without commits
+	repo, err = FindRepository(ws.GetPath("empty"))
+	if assert.Nil(err) {
+		submodules, err := repo.Submodules()
+		assert.Nil(err)
+		assert.Equal([]Submodule{}, submodules)
+	}
+}
+
+func TestMatchPathspecs(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		Pathspecs []string
+		Path      string
+		Matched   bool
+	}{
+		{[]string{"lib"}, "lib", true},
+		{[]string{"lib/"}, "lib", true},
+		{[]string{"lib"}, "lib2", false},
+		{[]string{"sub"}, "sub/lib", true},
+		{[]string{"*"}, "sub/lib", true},
+		{[]string{"sub/*", ":!sub/b"}, "sub/a", true},
+		{[]string{"sub/*", ":!sub/b"}, "sub/b", false},
+		{[]string{":(exclude)sub/b"}, "sub/a", true},
+		{[]string{":^sub/b"}, "sub/b", false},
+		{[]string{"."}, "any", true},
+	} {
+		assert.Equal(tc.Matched, matchPathspecs(tc.Pathspecs, tc.Path), "%v %s", tc.Pathspecs, tc.Path)
+	}
+}
@@ -0,0 +1,345 @@
+package gitconfig
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// UnmarshalError records a config variable which cannot be stored in a
+// struct field, Err is ErrRequired, ErrMissingValue or error of parsing.
+type UnmarshalError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *UnmarshalError) Error() string {
+	switch e.Err {
+	case ErrRequired:
+		return fmt.Sprintf("missing required config '%s'", e.Key)
+	case ErrMissingValue:
+		return fmt.Sprintf("missing value for '%s'", e.Key)
+	}
+	return fmt.Sprintf("bad config value '%s' for '%s': %s", e.Value, e.Key, e.Err)
+}
+
+// UnmarshalErrors holds all errors of Unmarshal
+type UnmarshalErrors []*UnmarshalError
+
+func (e UnmarshalErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fieldTag is parsed tag of a struct field, such as
+// `gitconfig:"core.abbrev,required"` or `gitconfig:"gc.auto,default=6700"`.
+// Default value is the rest of the tag after "default=".
+type fieldTag struct {
+	name         string
+	required     bool
+	omitEmpty    bool
+	hasDefault   bool
+	defaultValue string
+}
+
+func parseFieldTag(tag string) fieldTag {
+	items := strings.Split(tag, ",")
+	result := fieldTag{name: items[0]}
+	for i := 1; i < len(items); i++ {
+		switch {
+		case items[i] == "required":
+			result.required = true
+		case items[i] == "omitempty":
+			result.omitEmpty = true
+		case strings.HasPrefix(items[i], "default="):
+			result.hasDefault = true
+			result.defaultValue = strings.TrimPrefix(strings.Join(items[i:], ","), "default=")
+			return result
+		}
+	}
+	return result
+}
+
+// joinKey joins prefix (section or subsection) and name of a field
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// sectionName returns name of section as stored in GitConfig, name of
+// section is in lower case, but subsection is not.
+func sectionName(name string) string {
+	if pos := strings.IndexByte(name, '.'); pos >= 0 {
+		return strings.ToLower(name[:pos]) + name[pos:]
+	}
+	return strings.ToLower(name)
+}
+
+// isScalarType checks whether t is stored in one config variable
+func isScalarType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isStructType checks whether t (or *t) is a struct for a section
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isScalarType(t)
+}
+
+// isValuesType checks whether t is a scalar or a slice of scalars
+func isValuesType(t reflect.Type) bool {
+	return isScalarType(t) || t.Kind() == reflect.Slice && isScalarType(t.Elem())
+}
+
+// Unmarshal stores config into the struct pointed to by v. Fields are
+// mapped by tags like `gitconfig:"core.autocrlf"`:
+//
+//   - a scalar field gets the last value of the key, and a slice field
+//     gets all values of the key;
+//   - a struct field is a section (e.g. "core"), keys of its fields are
+//     relative to the section;
+//   - a map field with string keys is a section, whose values are
+//     subsections (e.g. "remote.<name>") if the map values are structs,
+//     or keys in the section (e.g. "alias.<name>") otherwise;
+//   - option "required" reports missing keys, and "default=value" sets
+//     value for missing keys.
+//
+// Fields without tag or with tag "-" are ignored, except that embedded
+// structs without tag are in the same section. Values are converted the
+// same as git, time.Duration and encoding.TextUnmarshaler are supported.
+// All bad values are reported together in UnmarshalErrors.
+func Unmarshal(cfg GitConfig, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal config into %T, need a pointer to struct", v)
+	}
+	d := &decoder{cfg: cfg}
+	if err := d.decodeStruct(rv.Elem(), ""); err != nil {
+		return err
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// decoder stores config into struct fields and collects errors
+type decoder struct {
+	cfg  GitConfig
+	errs UnmarshalErrors
+}
+
+func (d *decoder) decodeStruct(rv reflect.Value, prefix string) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("gitconfig")
+		if tag == "-" {
+			continue
+		}
+		if !ok {
+			if f.Anonymous && isStructType(f.Type) && f.Type.Kind() != reflect.Ptr {
+				if err := d.decodeStruct(rv.Field(i), prefix); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		opts := parseFieldTag(tag)
+		if err := d.decodeField(rv.Field(i), joinKey(prefix, opts.name), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeField(fv reflect.Value, key string, opts fieldTag) error {
+	t := fv.Type()
+	switch {
+	case isValuesType(t):
+		values := []gitConfigValue{}
+		if section, k := toSectionKey(key); section != "" && k != "" {
+			values = d.cfg.getRaw(key)
+		}
+		if len(values) == 0 {
+			if opts.required {
+				d.errs = append(d.errs, &UnmarshalError{Key: key, Err: ErrRequired})
+				return nil
+			}
+			if !opts.hasDefault {
+				return nil
+			}
+			values = []gitConfigValue{{value: opts.defaultValue}}
+		}
+		if isScalarType(t) {
+			d.decodeValue(fv, key, values[len(values)-1])
+			return nil
+		}
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			d.decodeValue(slice.Index(i), key, value)
+		}
+		fv.Set(slice)
+	case isStructType(t):
+		if t.Kind() == reflect.Ptr {
+			if !d.cfg.hasSection(sectionName(key)) {
+				return nil
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(t.Elem()))
+			}
+			fv = fv.Elem()
+		}
+		return d.decodeStruct(fv, key)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		(isStructType(t.Elem()) || isValuesType(t.Elem())):
+		return d.decodeMap(fv, key, opts)
+	default:
+		return fmt.Errorf("cannot unmarshal '%s' into unsupported type %s", key, t)
+	}
+	return nil
+}
+
+// decodeMap stores subsections or keys of section into map
+func (d *decoder) decodeMap(fv reflect.Value, key string, opts fieldTag) error {
+	t := fv.Type()
+	section := sectionName(key)
+	names := []string{}
+	if isStructType(t.Elem()) {
+		names = d.cfg.subsections(section)
+	} else if d.cfg[section] != nil {
+		for _, k := range d.cfg[section].Keys() {
+			if len(d.cfg[section][k]) > 0 {
+				names = append(names, k)
+			}
+		}
+	}
+	if len(names) == 0 {
+		if opts.required {
+			d.errs = append(d.errs, &UnmarshalError{Key: key, Err: ErrRequired})
+		}
+		return nil
+	}
+
+	if fv.IsNil() {
+		fv.Set(reflect.MakeMap(t))
+	}
+	for _, name := range names {
+		elem := reflect.New(t.Elem()).Elem()
+		if old := fv.MapIndex(reflect.ValueOf(name).Convert(t.Key())); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := d.decodeField(elem, section+"."+name, fieldTag{}); err != nil {
+			return err
+		}
+		fv.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+// decodeValue converts value and stores into fv, errors are collected
+func (d *decoder) decodeValue(fv reflect.Value, key string, value gitConfigValue) {
+	if err := setFieldValue(fv, value); err != nil {
+		if e, ok := err.(*NumError); ok {
+			err = e.Err
+		}
+		d.errs = append(d.errs, &UnmarshalError{Key: key, Value: value.value, Err: err})
+	}
+}
+
+// setFieldValue converts value the same as git and stores into fv
+func setFieldValue(fv reflect.Value, value gitConfigValue) error {
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setFieldValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+	if value.noValue && fv.Kind() != reflect.Bool {
+		return ErrMissingValue
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value.value))
+	}
+	if fv.Type() == durationType {
+		result, err := time.ParseDuration(value.value)
+		if err != nil {
+			return fmt.Errorf("invalid duration")
+		}
+		fv.SetInt(int64(result))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value.value)
+	case reflect.Bool:
+		result := true
+		if !value.noValue {
+			var err error
+			if result, err = ParseBool(value.value); err != nil {
+				return err
+			}
+		}
+		fv.SetBool(result)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result, err := ParseInt64(value.value)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(result) {
+			return ErrOutOfRange
+		}
+		fv.SetInt(result)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		result, err := ParseUint64(value.value)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowUint(result) {
+			return ErrOutOfRange
+		}
+		fv.SetUint(result)
+	case reflect.Float32, reflect.Float64:
+		result, err := strconv.ParseFloat(value.value, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid float")
+		}
+		fv.SetFloat(result)
+	}
+	return nil
+}
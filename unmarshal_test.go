@@ -0,0 +1,156 @@
+package gitconfig
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCoreConfig struct {
+	Bare        bool   `gitconfig:"bare"`
+	AutoCRLF    string `gitconfig:"autocrlf,default=false"`
+	Compression *int   `gitconfig:"compression"`
+}
+
+type testRemoteConfig struct {
+	URL    []string `gitconfig:"url"`
+	Fetch  []string `gitconfig:"fetch"`
+	Prune  *bool    `gitconfig:"prune"`
+	TagOpt string   `gitconfig:"tagOpt"`
+}
+
+type testUserConfig struct {
+	Name  string `gitconfig:"user.name,required"`
+	Email string `gitconfig:"user.email"`
+}
+
+type testConfig struct {
+	testUserConfig
+
+	Core       testCoreConfig              `gitconfig:"core"`
+	Remotes    map[string]testRemoteConfig `gitconfig:"remote"`
+	Aliases    map[string]string           `gitconfig:"alias"`
+	GCAuto     int                         `gitconfig:"gc.auto,default=6700"`
+	WindowSize uint64                      `gitconfig:"pack.windowMemory"`
+	Timeout    time.Duration               `gitconfig:"http.timeout,default=1m"`
+	Proxy      net.IP                      `gitconfig:"http.proxyAddr"`
+	Ratio      float64                     `gitconfig:"test.ratio"`
+	Ignored    string
+	Skipped    string `gitconfig:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		result testConfig
+	)
+
+	cfg, _, err := Parse([]byte(`
+[user]
+	name = Tester
+	email = tester@example.com
+[core]
+	bare
+	compression = 9
+[remote "origin"]
+	url = https://example.com/a.git
+	url = https://example.com/b.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	prune = yes
+[remote "Upstream"]
+	url = https://example.com/c.git
+	tagOpt = --no-tags
+[alias]
+	co = checkout
+	st = status -s
+[pack]
+	windowMemory = 1g
+[http]
+	proxyAddr = 10.0.0.1
+[test]
+	ratio = 0.5
+	ignored = value
+	skipped = value
+`), "")
+	assert.Nil(err)
+
+	result.Skipped = "keep"
+	assert.Nil(Unmarshal(cfg, &result))
+	assert.Equal("Tester", result.Name)
+	assert.Equal("tester@example.com", result.Email)
+	assert.True(result.Core.Bare)
+	assert.Equal("false", result.Core.AutoCRLF)
+	if assert.NotNil(result.Core.Compression) {
+		assert.Equal(9, *result.Core.Compression)
+	}
+	assert.Equal(2, len(result.Remotes))
+	assert.Equal([]string{"https://example.com/a.git", "https://example.com/b.git"},
+		result.Remotes["origin"].URL)
+	assert.Equal([]string{"+refs/heads/*:refs/remotes/origin/*"}, result.Remotes["origin"].Fetch)
+	if assert.NotNil(result.Remotes["origin"].Prune) {
+		assert.True(*result.Remotes["origin"].Prune)
+	}
+	assert.Nil(result.Remotes["Upstream"].Prune)
+	assert.Equal("--no-tags", result.Remotes["Upstream"].TagOpt)
+	assert.Equal(map[string]string{"co": "checkout", "st": "status -s"}, result.Aliases)
+	assert.Equal(6700, result.GCAuto)
+	assert.Equal(uint64(1<<30), result.WindowSize)
+	assert.Equal(time.Minute, result.Timeout)
+	assert.Equal("10.0.0.1", result.Proxy.String())
+	assert.Equal(0.5, result.Ratio)
+	assert.Equal("", result.Ignored)
+	assert.Equal("keep", result.Skipped)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		result testConfig
+	)
+
+	cfg, _, err := Parse([]byte(`
+[core]
+	bare = maybe
+	compression = 9x
+[remote "origin"]
+	prune = 2.0
+[gc]
+	auto = 99999999999999999999
+[http]
+	timeout = 10
+	proxyAddr
+`), "")
+	assert.Nil(err)
+
+	err = Unmarshal(cfg, &result)
+	errs, ok := err.(UnmarshalErrors)
+	if !assert.True(ok) {
+		return
+	}
+	msgs := []string{}
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	assert.Equal([]string{
+		"missing required config 'user.name'",
+		"bad config value 'maybe' for 'core.bare': not a bool value",
+		"bad config value '9x' for 'core.compression': invalid unit",
+		"bad config value '2.0' for 'remote.origin.prune': not a bool value",
+		"bad config value '99999999999999999999' for 'gc.auto': out of range",
+		"bad config value '10' for 'http.timeout': invalid duration",
+		"missing value for 'http.proxyAddr'",
+	}, msgs)
+	assert.Equal(ErrRequired, errs[0].Err)
+	assert.Equal("user.name", errs[0].Key)
+
+	assert.NotNil(Unmarshal(cfg, result))
+	assert.NotNil(Unmarshal(cfg, nil))
+
+	var bad struct {
+		Chan chan int `gitconfig:"test.chan"`
+	}
+	assert.Equal("cannot unmarshal 'test.chan' into unsupported type chan int",
+		Unmarshal(cfg, &bad).Error())
+}
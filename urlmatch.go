@@ -0,0 +1,365 @@
+package gitconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	urlUnsafeChars = " <>\"%{}|\\^`"
+	urlReserved    = ":/?#[]@!$&'()*+,;="
+	urlHostChars   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-[:]"
+)
+
+// urlInfo is a normalized URL split into parts, the same as "struct
+// url_info" of git.
+type urlInfo struct {
+	scheme  string
+	hasUser bool
+	user    string
+	host    string
+	port    string
+	// path includes query and fragment
+	path string
+}
+
+// urlMatch tells how good a URL pattern matches a URL, see cmp_matches()
+// of git.
+type urlMatch struct {
+	hostLen     int
+	pathLen     int
+	userMatched bool
+}
+
+// less tells whether m is a worse match than other
+func (m urlMatch) less(other urlMatch) bool {
+	if m.hostLen != other.hostLen {
+		return m.hostLen < other.hostLen
+	}
+	if m.pathLen != other.pathLen {
+		return m.pathLen < other.pathLen
+	}
+	return !m.userMatched && other.userMatched
+}
+
+// appendNormalizedEscapes normalizes %-escapes: unnecessary escapes are
+// decoded, unsafe characters and escaped characters in escOK are escaped
+// with uppercase hex digits.
+func appendNormalizedEscapes(buf *strings.Builder, s, escExtra, escOK string) error {
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		wasEsc := false
+		if ch == '%' {
+			if i+2 >= len(s) {
+				return fmt.Errorf("invalid %%XX escape sequence")
+			}
+			value, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return fmt.Errorf("invalid %%XX escape sequence")
+			}
+			ch = byte(value)
+			i += 2
+			wasEsc = true
+		}
+		if ch <= 0x1f || ch >= 0x7f ||
+			strings.IndexByte(urlUnsafeChars, ch) >= 0 ||
+			strings.IndexByte(escExtra, ch) >= 0 ||
+			(wasEsc && strings.IndexByte(escOK, ch) >= 0) {
+			fmt.Fprintf(buf, "%%%02X", ch)
+		} else {
+			buf.WriteByte(ch)
+		}
+	}
+	return nil
+}
+
+// normalizeEscapes returns s with normalized %-escapes
+func normalizeEscapes(s, escExtra, escOK string) (string, error) {
+	buf := strings.Builder{}
+	if err := appendNormalizedEscapes(&buf, s, escExtra, escOK); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// normalizeURL normalizes url the same as url_normalize() of git. Host
+// of URL pattern in config may have "*" as wildcard if allowGlobs is true.
+func normalizeURL(url string, allowGlobs bool) (*urlInfo, error) {
+	var err error
+
+	info := urlInfo{}
+
+	// Scheme
+	pos := strings.Index(url, "://")
+	if pos <= 0 || !isalpha(url[0]) {
+		return nil, fmt.Errorf("invalid URL scheme name or missing '://' suffix")
+	}
+	for i := 1; i < pos; i++ {
+		if !isalpha(url[i]) && !isdigit(url[i]) && strings.IndexByte("+.-", url[i]) < 0 {
+			return nil, fmt.Errorf("invalid URL scheme name or missing '://' suffix")
+		}
+	}
+	info.scheme = strings.ToLower(url[:pos])
+	url = url[pos+3:]
+
+	// User and password
+	slash := strings.IndexAny(url, "/?#")
+	if slash < 0 {
+		slash = len(url)
+	}
+	if at := strings.IndexByte(url, '@'); at >= 0 && at < slash {
+		info.hasUser = true
+		if info.user, err = normalizeEscapes(url[:at], "", urlReserved); err != nil {
+			return nil, err
+		}
+		if colon := strings.IndexByte(info.user, ':'); colon >= 0 {
+			info.user = info.user[:colon]
+		}
+		url = url[at+1:]
+		slash -= at + 1
+	}
+
+	// Host and port
+	hostChars := urlHostChars
+	if allowGlobs {
+		hostChars += "*"
+	}
+	for i := 0; i < slash; i++ {
+		if strings.IndexByte(hostChars, url[i]) < 0 {
+			return nil, fmt.Errorf("invalid characters in host name")
+		}
+	}
+	host := url[:slash]
+	if colon := strings.LastIndexAny(host, ":]"); colon >= 0 && host[colon] == ':' {
+		info.port = strings.TrimLeft(host[colon+1:], "0")
+		host = host[:colon]
+		if info.port != "" {
+			port, err := strconv.Atoi(info.port)
+			if err != nil || port > 65535 {
+				return nil, fmt.Errorf("invalid port number")
+			}
+		} else if colon+1 < slash {
+			return nil, fmt.Errorf("invalid port number")
+		}
+		if (info.scheme == "http" && info.port == "80") ||
+			(info.scheme == "https" && info.port == "443") {
+			info.port = ""
+		}
+	}
+	if host == "" && info.scheme != "file" {
+		return nil, fmt.Errorf("missing host and scheme is not 'file:'")
+	}
+	info.host = strings.ToLower(host)
+	url = url[slash:]
+
+	// Path, with "." and ".." segments removed
+	query := ""
+	if pos := strings.IndexAny(url, "?#"); pos >= 0 {
+		url, query = url[:pos], url[pos:]
+	}
+	segments := []string{}
+	if url != "" {
+		items := strings.Split(url[1:], "/")
+		for i, item := range items {
+			last := i == len(items)-1
+			switch item {
+			case ".":
+				if last {
+					segments = append(segments, "")
+				}
+				continue
+			case "..":
+				if len(segments) == 0 {
+					return nil, fmt.Errorf("invalid '..' path segment")
+				}
+				segments = segments[:len(segments)-1]
+				if last {
+					segments = append(segments, "")
+				}
+				continue
+			}
+			if item, err = normalizeEscapes(item, "", urlReserved); err != nil {
+				return nil, err
+			}
+			segments = append(segments, item)
+		}
+	}
+	info.path = "/" + strings.Join(segments, "/")
+	if query != "" {
+		if query, err = normalizeEscapes(query, "", urlReserved); err != nil {
+			return nil, err
+		}
+		info.path += query
+	}
+	return &info, nil
+}
+
+// matchHost matches host with pattern, "*" in pattern matches one part
+// of host name.
+func matchHost(host, pattern string) bool {
+	hostParts := strings.Split(host, ".")
+	patternParts := strings.Split(pattern, ".")
+	if len(hostParts) != len(patternParts) {
+		return false
+	}
+	for i := range hostParts {
+		if patternParts[i] != "*" && patternParts[i] != hostParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchURLPath matches path with prefix at path component boundary, and
+// returns length of matched prefix, or 0 for no match.
+func matchURLPath(path, prefix string) int {
+	if prefix == "" || prefix == "/" {
+		if path == "" || path[0] == '/' {
+			return 1
+		}
+		return 0
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return 0
+	}
+	if len(path) == len(prefix) || path[len(prefix)] == '/' {
+		return len(prefix) + 1
+	}
+	return 0
+}
+
+// matchURL matches url with pattern, the same as match_urls() of git
+func matchURL(url, pattern *urlInfo) (urlMatch, bool) {
+	m := urlMatch{}
+	if url.scheme != pattern.scheme {
+		return m, false
+	}
+	if pattern.hasUser {
+		if !url.hasUser || url.user != pattern.user {
+			return m, false
+		}
+		m.userMatched = true
+	}
+	if !matchHost(url.host, pattern.host) || url.port != pattern.port {
+		return m, false
+	}
+	if m.pathLen = matchURLPath(url.path, pattern.path); m.pathLen == 0 {
+		return m, false
+	}
+	m.hostLen = len(pattern.host)
+	return m, true
+}
+
+// scopeOrder returns order of scope in which git reads config
+func scopeOrder(s scope) int {
+	switch s & ScopeMask {
+	case ScopeSystem:
+		return 1
+	case ScopeGlobal:
+		return 2
+	case ScopeSelf:
+		return 3
+	case ScopeWorktree:
+		return 4
+	case ScopeCommand:
+		return 5
+	}
+	return 0
+}
+
+// laterValue tells whether value a is read after b by git: scopes are
+// read in order of system, global, repository, worktree and command
+// line, and values in the same file are read in order of lines. Values
+// from different files of the same scope (included files) are not
+// ordered.
+func laterValue(a, b gitConfigValue) bool {
+	if oa, ob := scopeOrder(a.scope), scopeOrder(b.scope); oa != ob {
+		return oa > ob
+	}
+	return a.origin.Type == b.origin.Type && a.origin.Name == b.origin.Name &&
+		a.origin.Line > b.origin.Line
+}
+
+// URLMatch returns values of variables in section which best match url,
+// the same as "git config --get-urlmatch <name> <url>". The name is a
+// section (e.g. "http") or a variable (e.g. "http.proxy"). Variables are
+// defined as "<section>.<url>.<key>" or "<section>.<key>", and the most
+// specific URL pattern wins: longer host name, longer path, and having
+// user name are compared in order. For equally specific patterns, the
+// last one in config wins. Results are sorted by key.
+func (v GitConfig) URLMatch(name, url string) ([]ConfigValue, error) {
+	info, err := normalizeURL(url, false)
+	if err != nil {
+		return nil, fmt.Errorf("bad URL '%s': %s", url, err)
+	}
+
+	section, key := strings.ToLower(name), ""
+	if pos := strings.Index(section, "."); pos >= 0 {
+		section, key = section[:pos], section[pos+1:]
+	}
+
+	matches := map[string]urlMatch{}
+	raws := map[string]gitConfigValue{}
+	values := map[string]ConfigValue{}
+	for _, s := range v.Sections() {
+		m := urlMatch{}
+		if s != section {
+			if !strings.HasPrefix(s, section+".") {
+				continue
+			}
+			pattern, err := normalizeURL(strings.TrimPrefix(s, section+"."), true)
+			if err != nil {
+				continue
+			}
+			var ok bool
+			if m, ok = matchURL(info, pattern); !ok {
+				continue
+			}
+		}
+		for _, k := range v[s].Keys() {
+			if key != "" && k != key {
+				continue
+			}
+			all := v.GetAllWithOrigin(s + "." + k)
+			if len(all) == 0 {
+				continue
+			}
+			raw := v[s][k][len(v[s][k])-1]
+			if old, ok := matches[k]; ok {
+				// Equally specific, the later one in config wins
+				if m.less(old) || !old.less(m) && !laterValue(raw, raws[k]) {
+					continue
+				}
+			}
+			value := all[len(all)-1]
+			value.Key = section + "." + k
+			matches[k] = m
+			raws[k] = raw
+			values[k] = value
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := []ConfigValue{}
+	for _, k := range keys {
+		result = append(result, values[k])
+	}
+	return result, nil
+}
+
+// GetURLMatch returns value of variable (e.g. "http.proxy") which best
+// matches url, or empty string if not found or url is bad.
+func (v GitConfig) GetURLMatch(key, url string) string {
+	values, err := v.URLMatch(key, url)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0].Value
+}
@@ -0,0 +1,389 @@
+package gitconfig
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWatchInterval is interval to check config files if not set
+	defaultWatchInterval = 2 * time.Second
+)
+
+// ChangeType is type of change of a config variable
+type ChangeType int
+
+// Types of change events
+const (
+	KeyAdded ChangeType = iota + 1
+	KeyChanged
+	KeyRemoved
+)
+
+func (v ChangeType) String() string {
+	switch v {
+	case KeyAdded:
+		return "added"
+	case KeyChanged:
+		return "changed"
+	case KeyRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// ChangeEvent describes change of a config variable. OldValues is nil
+// for added key, and NewValues is nil for removed key.
+type ChangeEvent struct {
+	Type      ChangeType
+	Key       string
+	OldValues []string
+	NewValues []string
+}
+
+// OldValue returns the last old value, which was in effect
+func (v ChangeEvent) OldValue() string {
+	if len(v.OldValues) == 0 {
+		return ""
+	}
+	return v.OldValues[len(v.OldValues)-1]
+}
+
+// NewValue returns the last new value, which is in effect
+func (v ChangeEvent) NewValue() string {
+	if len(v.NewValues) == 0 {
+		return ""
+	}
+	return v.NewValues[len(v.NewValues)-1]
+}
+
+// Diff compares config with newCfg, and returns change events sorted
+// by key.
+func (v GitConfig) Diff(newCfg GitConfig) []ChangeEvent {
+	keys := v.Keys()
+	for _, k := range newCfg.Keys() {
+		if !v.HasKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	events := []ChangeEvent{}
+	for _, k := range keys {
+		oldValues := v.GetAll(k)
+		newValues := newCfg.GetAll(k)
+		if len(oldValues) == 0 && len(newValues) == 0 {
+			continue
+		}
+		event := ChangeEvent{
+			Key:       k,
+			OldValues: oldValues,
+			NewValues: newValues,
+		}
+		if len(oldValues) == 0 {
+			event.Type = KeyAdded
+			event.OldValues = nil
+		} else if len(newValues) == 0 {
+			event.Type = KeyRemoved
+			event.NewValues = nil
+		} else if !equalStrings(oldValues, newValues) {
+			event.Type = KeyChanged
+		} else {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// notifier notifies changes of files in watched directories
+type notifier interface {
+	// watch replaces the set of watched directories
+	watch(dirs []string) error
+	// events receives a value when files in watched directories change
+	events() <-chan struct{}
+	close() error
+}
+
+// Watcher watches config of a repository, including system, global,
+// repository and per-worktree config files and all included files, and
+// delivers change events of config variables.
+//
+// Files are watched using inotify on Linux, and are also checked every
+// interval as a fallback. Files are read from the default file system
+// (see SetFS) at the time the watcher is created.
+type Watcher struct {
+	// Events receives change events, unless a callback is used
+	Events chan ChangeEvent
+	// Errors receives errors of watching and loading config, which are
+	// not fatal. Config is not changed if any file fails to load.
+	Errors chan error
+
+	fsys     FS
+	dir      string
+	interval time.Duration
+	callback func(ChangeEvent)
+	notifier notifier
+
+	mutex      sync.Mutex
+	cfg        GitConfig
+	files      []fileStat
+	inCallback bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	wg        sync.WaitGroup
+}
+
+// NewWatcher creates watcher for config of repository in dir (current
+// directory if dir is empty). Change events are sent to Events. Files
+// are checked every interval if notification of OS is not available,
+// or 2 seconds if interval is not positive.
+func NewWatcher(dir string, interval time.Duration) (*Watcher, error) {
+	return newWatcher(dir, interval, nil)
+}
+
+// NewWatcherFunc creates watcher like NewWatcher, but change events are
+// passed to fn in the goroutine of watcher instead of Events. fn may
+// call Close of the watcher to stop watching.
+func NewWatcherFunc(dir string, interval time.Duration, fn func(ChangeEvent)) (*Watcher, error) {
+	return newWatcher(dir, interval, fn)
+}
+
+func newWatcher(dir string, interval time.Duration, fn func(ChangeEvent)) (*Watcher, error) {
+	var err error
+
+	if dir == "" {
+		dir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	v := &Watcher{
+		Errors:   make(chan error, 1),
+		fsys:     GetFS(),
+		dir:      dir,
+		interval: interval,
+		callback: fn,
+		done:     make(chan struct{}),
+	}
+	if fn == nil {
+		v.Events = make(chan ChangeEvent)
+	}
+
+	files := &fileStats{}
+	v.cfg, err = loadDirWithDefault(v.fsys, dir, files)
+	v.files = files.files
+	if err != nil {
+		v.sendError(err)
+	}
+
+	// Fallback to polling if notification of OS is not available, or
+	// files are not in the file system of the OS.
+	if isOSFS(v.fsys) {
+		if n, err := newNotifier(); err == nil && n != nil {
+			v.notifier = n
+			v.watch()
+		}
+	}
+
+	v.wg.Add(1)
+	go v.run()
+	return v, nil
+}
+
+// Config returns a copy of the latest config
+func (v *Watcher) Config() GitConfig {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.cfg.Clone()
+}
+
+// Close stops watching, and closes Events and Errors. If Close is
+// called while the callback of NewWatcherFunc is running, such as from
+// the callback, it returns without waiting, and the watcher stops after
+// the callback returns.
+func (v *Watcher) Close() error {
+	v.closeOnce.Do(func() {
+		close(v.done)
+	})
+
+	v.mutex.Lock()
+	inCallback := v.inCallback
+	v.mutex.Unlock()
+	if inCallback {
+		return nil
+	}
+	v.wg.Wait()
+	return v.closeErr
+}
+
+// stop closes notifier, Events and Errors when the goroutine exits.
+// Notifier is closed after the goroutine stops adding watches on it.
+func (v *Watcher) stop() {
+	if v.notifier != nil {
+		v.closeErr = v.notifier.close()
+	}
+	if v.Events != nil {
+		close(v.Events)
+	}
+	close(v.Errors)
+	v.wg.Done()
+}
+
+// runCallback passes event to callback, and tells whether the watcher
+// is closed.
+func (v *Watcher) runCallback(event ChangeEvent) bool {
+	v.mutex.Lock()
+	v.inCallback = true
+	v.mutex.Unlock()
+	defer func() {
+		v.mutex.Lock()
+		v.inCallback = false
+		v.mutex.Unlock()
+	}()
+
+	v.callback(event)
+	select {
+	case <-v.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// dirs returns directories of files to watch. For missing directory,
+// the nearest existing parent directory is watched instead.
+func (v *Watcher) dirs() []string {
+	dirs := []string{}
+	seen := make(map[string]bool)
+	for _, file := range v.files {
+		dir := filepath.Dir(file.filename)
+		for {
+			if fi, err := statFile(v.fsys, dir); err == nil && fi.IsDir() {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// sendError sends error without blocking, and drops it if nobody reads
+func (v *Watcher) sendError(err error) {
+	select {
+	case v.Errors <- err:
+	default:
+	}
+}
+
+func (v *Watcher) run() {
+	var notify <-chan struct{}
+
+	defer v.stop()
+
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	if v.notifier != nil {
+		notify = v.notifier.events()
+	}
+	for {
+		select {
+		case <-v.done:
+			return
+		case <-ticker.C:
+		case <-notify:
+		}
+		for _, event := range v.check() {
+			if v.callback != nil {
+				if v.runCallback(event) {
+					return
+				}
+				continue
+			}
+			select {
+			case v.Events <- event:
+			case <-v.done:
+				return
+			}
+		}
+	}
+}
+
+// uptodate checks whether all files are not changed
+func (v *Watcher) uptodate() bool {
+	for _, file := range v.files {
+		if !file.uptodate() {
+			return false
+		}
+	}
+	return true
+}
+
+// watch updates directories to watch
+func (v *Watcher) watch() {
+	if v.notifier == nil {
+		return
+	}
+	if err := v.notifier.watch(v.dirs()); err != nil {
+		v.sendError(err)
+	}
+}
+
+// check reloads config if any file is changed, and returns changes
+func (v *Watcher) check() []ChangeEvent {
+	// Missing directory of files may be created, update watches before
+	// checking files, so that no change is missed.
+	v.watch()
+	if v.uptodate() {
+		return nil
+	}
+
+	// Keep the old config if any file fails to load, e.g. it is being
+	// written or has a syntax error. Otherwise values of the file are
+	// reported as removed, and then added again after it is fixed.
+	files := &fileStats{}
+	cfg, err := loadDirWithDefault(v.fsys, v.dir, files)
+	if err != nil {
+		v.sendError(err)
+		return nil
+	}
+
+	v.mutex.Lock()
+	events := v.cfg.Diff(cfg)
+	v.cfg = cfg
+	v.files = files.files
+	v.mutex.Unlock()
+
+	// Included files may be added or removed
+	v.watch()
+	return events
+}
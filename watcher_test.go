@@ -0,0 +1,345 @@
+package gitconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	oldCfg := NewGitConfig()
+	oldCfg.Add("a.changed", "1")
+	oldCfg.Add("a.removed", "2")
+	oldCfg.Add("a.same", "3")
+	oldCfg.Add("a.multi", "4", "5")
+	newCfg := NewGitConfig()
+	newCfg.Add("a.changed", "11")
+	newCfg.Add("a.same", "3")
+	newCfg.Add("a.multi", "4", "5", "6")
+	newCfg.Add("b.c.added", "7")
+
+	assert.Equal([]ChangeEvent{
+		{Type: KeyChanged, Key: "a.changed", OldValues: []string{"1"}, NewValues: []string{"11"}},
+		{Type: KeyChanged, Key: "a.multi", OldValues: []string{"4", "5"}, NewValues: []string{"4", "5", "6"}},
+		{Type: KeyRemoved, Key: "a.removed", OldValues: []string{"2"}},
+		{Type: KeyAdded, Key: "b.c.added", NewValues: []string{"7"}},
+	}, oldCfg.Diff(newCfg))
+	assert.Equal([]ChangeEvent{}, oldCfg.Diff(oldCfg.Clone()))
+
+	event := ChangeEvent{Type: KeyChanged, OldValues: []string{"4", "5"}, NewValues: []string{"6"}}
+	assert.Equal("changed", event.Type.String())
+	assert.Equal("5", event.OldValue())
+	assert.Equal("6", event.NewValue())
+}
+
+func TestWatcher(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		home   string
+		err    error
+	)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	home, err = homeDir()
+	assert.Nil(err)
+	defer func(home string) {
+		setHome(home)
+	}(home)
+	setHome(tmpdir)
+
+	sysCfgFile := filepath.Join(tmpdir, "system-config")
+	os.Setenv(gitSystemConfigEnv, sysCfgFile)
+	defer os.Unsetenv(gitSystemConfigEnv)
+	userCfgFile := filepath.Join(tmpdir, ".gitconfig")
+	incCfgFile := filepath.Join(tmpdir, "inc", "work")
+	repoDir := filepath.Join(tmpdir, "repo")
+	repoCfgFile := filepath.Join(repoDir, ".git", "config")
+
+	assert.Nil(exec.Command("git", "init", "-q", repoDir).Run())
+	assert.Nil(exec.Command("git", "config", "-f", userCfgFile, "include.path", "inc/work").Run())
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "test.repo", "repo").Run())
+
+	// Use inotify on Linux, otherwise check files frequently
+	interval := time.Hour
+	if runtime.GOOS != "linux" {
+		interval = 50 * time.Millisecond
+	}
+	w, err := NewWatcher(repoDir, interval)
+	if !assert.Nil(err) {
+		return
+	}
+	defer w.Close()
+	assert.Equal("repo", w.Config().Get("test.repo"))
+
+	expectEvent := func(expect ChangeEvent) {
+		for {
+			select {
+			case event := <-w.Events:
+				if event.Key != expect.Key {
+					continue
+				}
+				assert.Equal(expect, event)
+				return
+			case <-time.After(5 * time.Second):
+				assert.Fail("timeout", "wait for event of %s", expect.Key)
+				return
+			}
+		}
+	}
+
+	// System config file is created
+	assert.Nil(exec.Command("git", "config", "-f", sysCfgFile, "test.sys", "sys").Run())
+	expectEvent(ChangeEvent{Type: KeyAdded, Key: "test.sys", NewValues: []string{"sys"}})
+
+	// Missing included file is created
+	assert.Nil(os.MkdirAll(filepath.Dir(incCfgFile), 0755))
+	assert.Nil(exec.Command("git", "config", "-f", incCfgFile, "test.inc", "inc").Run())
+	expectEvent(ChangeEvent{Type: KeyAdded, Key: "test.inc", NewValues: []string{"inc"}})
+
+	// Included file is changed
+	assert.Nil(exec.Command("git", "config", "-f", incCfgFile, "test.inc", "work").Run())
+	expectEvent(ChangeEvent{Type: KeyChanged, Key: "test.inc", OldValues: []string{"inc"}, NewValues: []string{"work"}})
+
+	// Repository config is changed
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "--unset", "test.repo").Run())
+	expectEvent(ChangeEvent{Type: KeyRemoved, Key: "test.repo", OldValues: []string{"repo"}})
+	assert.Equal("", w.Config().Get("test.repo"))
+	assert.Equal("work", w.Config().Get("test.inc"))
+
+	assert.Nil(w.Close())
+	_, ok := <-w.Events
+	assert.False(ok)
+}
+
+func TestWatcherFunc(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	repoDir := filepath.Join(tmpdir, "repo")
+	repoCfgFile := filepath.Join(repoDir, ".git", "config")
+	assert.Nil(exec.Command("git", "init", "-q", repoDir).Run())
+
+	ch := make(chan ChangeEvent, 10)
+	w, err := NewWatcherFunc(repoDir, 50*time.Millisecond, func(event ChangeEvent) {
+		ch <- event
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	defer w.Close()
+	assert.Nil(w.Events)
+
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "test.func", "value").Run())
+	for {
+		select {
+		case event := <-ch:
+			if event.Key != "test.func" {
+				continue
+			}
+			assert.Equal(KeyAdded, event.Type)
+			assert.Equal("value", event.NewValue())
+			return
+		case <-time.After(5 * time.Second):
+			assert.Fail("timeout")
+			return
+		}
+	}
+}
+
+func TestWatcherCloseWhileChanging(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	repoDir := filepath.Join(tmpdir, "repo")
+	repoCfgFile := filepath.Join(repoDir, ".git", "config")
+	incDir := filepath.Join(tmpdir, "inc")
+	assert.Nil(exec.Command("git", "init", "-q", repoDir).Run())
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "include.path", "../../inc/config").Run())
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			// Directory of included file comes and goes, which
+			// changes the set of watched directories. File is
+			// renamed into place, so it is never half-written.
+			if i%2 == 0 {
+				os.MkdirAll(incDir, 0755)
+				ioutil.WriteFile(filepath.Join(incDir, "config.tmp"),
+					[]byte("[test]\n\tvalue = "+strconv.Itoa(i)+"\n"), 0644)
+				os.Rename(filepath.Join(incDir, "config.tmp"), filepath.Join(incDir, "config"))
+			} else {
+				os.RemoveAll(incDir)
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		w, err := NewWatcherFunc(repoDir, time.Millisecond, func(ChangeEvent) {})
+		if !assert.Nil(err) {
+			break
+		}
+		time.Sleep(time.Duration(i%5) * time.Millisecond)
+		assert.Nil(w.Close())
+		// Watches must not be added after notifier is closed
+		for err := range w.Errors {
+			assert.Nil(err)
+		}
+	}
+	close(done)
+	<-stopped
+}
+
+func TestWatcherBrokenConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	repoDir := filepath.Join(tmpdir, "repo")
+	repoCfgFile := filepath.Join(repoDir, ".git", "config")
+	assert.Nil(exec.Command("git", "init", "-q", repoDir).Run())
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "test.a", "1").Run())
+	assert.Nil(exec.Command("git", "config", "-f", repoCfgFile, "test.b", "2").Run())
+	data, err := ioutil.ReadFile(repoCfgFile)
+	assert.Nil(err)
+
+	ch := make(chan ChangeEvent, 100)
+	w, err := NewWatcherFunc(repoDir, 50*time.Millisecond, func(event ChangeEvent) {
+		ch <- event
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	defer w.Close()
+
+	// Replace config by renaming, so it is never seen half-written
+	writeConfig := func(data []byte) {
+		assert.Nil(ioutil.WriteFile(repoCfgFile+".tmp", data, 0644))
+		assert.Nil(os.Rename(repoCfgFile+".tmp", repoCfgFile))
+	}
+
+	// Values of broken config are kept, and no event is sent
+	writeConfig(append(data, []byte("[test\n")...))
+	select {
+	case err := <-w.Errors:
+		assert.NotNil(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("timeout", "wait for error")
+	}
+	select {
+	case event := <-ch:
+		assert.Fail("unexpected event", "%v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+	assert.Equal("1", w.Config().Get("test.a"))
+
+	// Only the changed value is reported after config is fixed
+	writeConfig(append(data, []byte("[test]\n\tb = 3\n")...))
+	for {
+		select {
+		case event := <-ch:
+			if event.Key != "test.b" {
+				assert.Fail("unexpected event", "%v", event)
+				continue
+			}
+			assert.Equal(ChangeEvent{Type: KeyChanged, Key: "test.b", OldValues: []string{"2"}, NewValues: []string{"2", "3"}}, event)
+			return
+		case <-time.After(5 * time.Second):
+			assert.Fail("timeout", "wait for event of test.b")
+			return
+		}
+	}
+}
+
+func TestWatcherCloseInCallback(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "gitconfig")
+	if err != nil {
+		panic(err)
+	}
+	defer func(dir string) {
+		os.RemoveAll(dir)
+	}(tmpdir)
+
+	repoDir := filepath.Join(tmpdir, "repo")
+	repoCfgFile := filepath.Join(repoDir, ".git", "config")
+	assert.Nil(exec.Command("git", "init", "-q", repoDir).Run())
+
+	var w *Watcher
+	ready := make(chan struct{})
+	ch := make(chan ChangeEvent, 10)
+	w, err = NewWatcherFunc(repoDir, 50*time.Millisecond, func(event ChangeEvent) {
+		<-ready
+		ch <- event
+		// Stop after the first change, must not deadlock
+		assert.Nil(w.Close())
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	close(ready)
+
+	data, err := ioutil.ReadFile(repoCfgFile)
+	assert.Nil(err)
+	data = append(data, []byte("[test]\n\ta = 1\n\tb = 2\n")...)
+	assert.Nil(ioutil.WriteFile(repoCfgFile+".tmp", data, 0644))
+	assert.Nil(os.Rename(repoCfgFile+".tmp", repoCfgFile))
+
+	stopped := make(chan struct{})
+	go func() {
+		for range w.Errors {
+		}
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		assert.Fail("timeout", "wait for watcher to stop")
+		return
+	}
+	assert.Nil(w.Close())
+	assert.Equal(1, len(ch))
+}
@@ -0,0 +1,311 @@
+package gitconfig
+
+// This is a port of wildmatch.c of git, which is used to match
+// patterns of conditional includes.
+
+const (
+	// wmCaseFold makes the match case insensitive
+	wmCaseFold = 1 << iota
+	// wmPathname makes "*" and "?" not to match "/"
+	wmPathname
+)
+
+const (
+	wmNoMatch = iota + 1
+	wmMatch
+	wmAbortAll
+	wmAbortToStarStar
+)
+
+// wildmatch returns true if text matches the pattern
+func wildmatch(pattern, text string, flags int) bool {
+	return dowild(pattern, text, flags) == wmMatch
+}
+
+// charAt returns the char at index i, or 0 if out of range
+func charAt(s string, i int) byte {
+	if i < 0 || i >= len(s) {
+		return 0
+	}
+	return s[i]
+}
+
+func isGlobSpecial(c byte) bool {
+	return c == '*' || c == '?' || c == '[' || c == '\\'
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func toUpper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// matchCharClass checks whether c is in a class like "alpha", "digit"
+func matchCharClass(class string, c byte) (bool, bool) {
+	switch class {
+	case "alnum":
+		return isalpha(c) || isdigit(c), true
+	case "alpha":
+		return isalpha(c), true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isdigit(c), true
+	case "graph":
+		return c > 0x20 && c < 0x7f, true
+	case "lower":
+		return c >= 'a' && c <= 'z', true
+	case "print":
+		return c >= 0x20 && c < 0x7f, true
+	case "punct":
+		return c > 0x20 && c < 0x7f && !isalpha(c) && !isdigit(c), true
+	case "space":
+		return isspace(c), true
+	case "upper":
+		return c >= 'A' && c <= 'Z', true
+	case "xdigit":
+		return isdigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	}
+	return false, false
+}
+
+func isalpha(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isdigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func dowild(pattern, text string, flags int) int {
+	var (
+		p = 0
+		t = 0
+	)
+
+	for ; p < len(pattern); t, p = t+1, p+1 {
+		var (
+			pCh        = pattern[p]
+			tCh        = charAt(text, t)
+			matchSlash bool
+		)
+
+		if tCh == 0 && pCh != '*' {
+			return wmAbortAll
+		}
+		if flags&wmCaseFold != 0 {
+			tCh = toLower(tCh)
+			pCh = toLower(pCh)
+		}
+		switch pCh {
+		case '\\':
+			p++
+			pCh = charAt(pattern, p)
+			if flags&wmCaseFold != 0 {
+				pCh = toLower(pCh)
+			}
+			if tCh != pCh {
+				return wmNoMatch
+			}
+			continue
+		default:
+			if tCh != pCh {
+				return wmNoMatch
+			}
+			continue
+		case '?':
+			if flags&wmPathname != 0 && tCh == '/' {
+				return wmNoMatch
+			}
+			continue
+		case '*':
+			p++
+			if charAt(pattern, p) == '*' {
+				prevP := p - 2
+				for p++; charAt(pattern, p) == '*'; p++ {
+				}
+				if (prevP < 0 || pattern[prevP] == '/') &&
+					(p >= len(pattern) || pattern[p] == '/' ||
+						(pattern[p] == '\\' && charAt(pattern, p+1) == '/')) {
+					if charAt(pattern, p) == '/' &&
+						dowild(pattern[p+1:], text[t:], flags) == wmMatch {
+						return wmMatch
+					}
+					matchSlash = true
+				} else {
+					matchSlash = false
+				}
+			} else {
+				matchSlash = flags&wmPathname == 0
+			}
+			if p >= len(pattern) {
+				if !matchSlash {
+					for i := t; i < len(text); i++ {
+						if text[i] == '/' {
+							return wmNoMatch
+						}
+					}
+				}
+				return wmMatch
+			} else if !matchSlash && pattern[p] == '/' {
+				slash := -1
+				for i := t; i < len(text); i++ {
+					if text[i] == '/' {
+						slash = i
+						break
+					}
+				}
+				if slash < 0 {
+					return wmNoMatch
+				}
+				t = slash
+				continue
+			}
+			for {
+				if tCh == 0 {
+					break
+				}
+				if !isGlobSpecial(pattern[p]) {
+					pCh = pattern[p]
+					if flags&wmCaseFold != 0 {
+						pCh = toLower(pCh)
+					}
+					for tCh = charAt(text, t); tCh != 0 && (matchSlash || tCh != '/'); tCh = charAt(text, t) {
+						if flags&wmCaseFold != 0 {
+							tCh = toLower(tCh)
+						}
+						if tCh == pCh {
+							break
+						}
+						t++
+					}
+					if tCh != pCh {
+						return wmNoMatch
+					}
+				}
+				matched := dowild(pattern[p:], text[t:], flags)
+				if matched != wmNoMatch {
+					if !matchSlash || matched != wmAbortToStarStar {
+						return matched
+					}
+				} else if !matchSlash && tCh == '/' {
+					return wmAbortToStarStar
+				}
+				t++
+				tCh = charAt(text, t)
+				if flags&wmCaseFold != 0 {
+					tCh = toLower(tCh)
+				}
+			}
+			return wmAbortAll
+		case '[':
+			var (
+				prevCh  byte
+				matched bool
+				negated bool
+			)
+			p++
+			pCh = charAt(pattern, p)
+			if pCh == '^' {
+				pCh = '!'
+			}
+			if pCh == '!' {
+				negated = true
+				p++
+				pCh = charAt(pattern, p)
+			}
+			for {
+				if pCh == 0 {
+					return wmAbortAll
+				}
+				if pCh == '\\' {
+					p++
+					pCh = charAt(pattern, p)
+					if pCh == 0 {
+						return wmAbortAll
+					}
+					if tCh == pCh {
+						matched = true
+					}
+				} else if pCh == '-' && prevCh != 0 && charAt(pattern, p+1) != 0 && charAt(pattern, p+1) != ']' {
+					p++
+					pCh = pattern[p]
+					if pCh == '\\' {
+						p++
+						pCh = charAt(pattern, p)
+						if pCh == 0 {
+							return wmAbortAll
+						}
+					}
+					if tCh <= pCh && tCh >= prevCh {
+						matched = true
+					} else if flags&wmCaseFold != 0 {
+						upper := toUpper(tCh)
+						if upper <= pCh && upper >= prevCh {
+							matched = true
+						}
+					}
+					pCh = 0
+				} else if pCh == '[' && charAt(pattern, p+1) == ':' {
+					start := p + 2
+					end := start
+					for end < len(pattern) && pattern[end] != ']' {
+						end++
+					}
+					if end >= len(pattern) {
+						return wmAbortAll
+					}
+					i := end - start - 1
+					if i < 0 || pattern[end-1] != ':' {
+						// Didn't find ":]", so treat like a normal set.
+						p = start - 2
+						pCh = '['
+						if tCh == pCh {
+							matched = true
+						}
+					} else {
+						ok, valid := matchCharClass(pattern[start:end-1], tCh)
+						if !valid {
+							return wmAbortAll
+						}
+						if !ok && flags&wmCaseFold != 0 && pattern[start:end-1] == "upper" {
+							ok = tCh >= 'a' && tCh <= 'z'
+						}
+						if ok {
+							matched = true
+						}
+						p = end
+						pCh = 0
+					}
+				} else if tCh == pCh {
+					matched = true
+				}
+				prevCh = pCh
+				p++
+				pCh = charAt(pattern, p)
+				if pCh == ']' {
+					break
+				}
+			}
+			if matched == negated || (flags&wmPathname != 0 && tCh == '/') {
+				return wmNoMatch
+			}
+			continue
+		}
+	}
+
+	if t < len(text) {
+		return wmNoMatch
+	}
+	return wmMatch
+}
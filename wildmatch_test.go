@@ -0,0 +1,52 @@
+package gitconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildmatch(t *testing.T) {
+	for _, tc := range []struct {
+		Pattern string
+		Text    string
+		Flags   int
+		Match   bool
+	}{
+		{"foo", "foo", 0, true},
+		{"bar", "foo", 0, false},
+		{"???", "foo", 0, true},
+		{"*f", "foo", 0, false},
+		{"*", "foo/bar", 0, true},
+		{"*", "foo/bar", wmPathname, false},
+		{"foo/*", "foo/bar", wmPathname, true},
+		{"foo/*", "foo/bar/baz", wmPathname, false},
+		{"foo/**", "foo/bar/baz", wmPathname, true},
+		{"**/foo", "foo", wmPathname, true},
+		{"**/foo", "a/b/foo", wmPathname, true},
+		{"**/foo/**", "/home/user/foo/.git", wmPathname, true},
+		{"**/bar*", "deep/foo/bar/baz", wmPathname, false},
+		{"**/bar/*", "deep/foo/bar/baz", wmPathname, true},
+		{"foo?bar", "foo/bar", wmPathname, false},
+		{"[ab]x", "bx", 0, true},
+		{"[!ab]x", "bx", 0, false},
+		{"[^ab]x", "cx", 0, true},
+		{"[a-c]x", "bx", 0, true},
+		{"[a-c]x", "Bx", 0, false},
+		{"[a-c]x", "Bx", wmCaseFold, true},
+		{"[[:digit:]]", "5", 0, true},
+		{"[[:alpha:]]", "5", 0, false},
+		{"FOO/**", "foo/bar", wmPathname | wmCaseFold, true},
+		{"FOO/**", "foo/bar", wmPathname, false},
+		{`\*`, "*", 0, true},
+		{`\*`, "a", 0, false},
+		{"https://example.com/**", "https://example.com/a/b.git", wmPathname, true},
+		{"https://*.com/**", "https://example.com/a/b.git", wmPathname, true},
+	} {
+		assert.Equal(t,
+			tc.Match,
+			wildmatch(tc.Pattern, tc.Text, tc.Flags),
+			"wildmatch('%s', '%s', %d)",
+			tc.Pattern, tc.Text, tc.Flags)
+	}
+}